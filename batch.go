@@ -0,0 +1,102 @@
+package cuckoo
+
+// probe is a hashed key of a batch, k is its position in the batch
+type probe struct {
+	i   uint32
+	tag uint64
+	k   int
+}
+
+// InsertBatch adds keys like Add and returns the error of every key, nil
+// for the added ones. The keys are hashed first and added in bucket order,
+// so when the filter fills up the keys that fail are not the last ones.
+func (c *Cuckoo) InsertBatch(keys [][]byte) []error {
+	errs := make([]error, len(keys))
+	for _, p := range c.probes(keys) {
+		errs[p.k] = c.addTag(p.i, p.tag)
+	}
+
+	return errs
+}
+
+// ContainBatch sets out[k] to Contain(keys[k]), out must be at least as long
+// as keys. The keys are hashed first and looked up in bucket order, which
+// keeps the table reads close to each other.
+func (c *Cuckoo) ContainBatch(keys [][]byte, out []bool) {
+	_ = out[:len(keys)]
+	ps := c.probes(keys)
+	for len(ps) > 0 {
+		// touched buckets of a chunk stay in the cache until searched
+		chunk := ps
+		if len(chunk) > touchChunk {
+			chunk = chunk[:touchChunk]
+		}
+		ps = ps[len(chunk):]
+
+		c.touch(chunk)
+		for _, p := range chunk {
+			out[p.k] = c.containTag(p.i, p.tag)
+		}
+	}
+}
+
+// touchChunk is the number of probes touched ahead of the lookups
+const touchChunk = 4096
+
+// touch loads both buckets of every probe before they are searched. Go has
+// no prefetch instruction, the loads do not depend on each other so the
+// cache misses overlap instead of being taken one lookup at a time.
+func (c *Cuckoo) touch(ps []probe) {
+	var sink byte
+	switch t := c.table.(type) {
+	case *singleTable:
+		for _, p := range ps {
+			i2 := c.altIndex(p.i, p.tag)
+			sink ^= t.buckets[int(p.i)*t.kBytesPerBucket] ^ t.buckets[int(i2)*t.kBytesPerBucket]
+		}
+	case *PackedTable:
+		for _, p := range ps {
+			i2 := c.altIndex(p.i, p.tag)
+			b1, _ := t.bucket(p.i)
+			b2, _ := t.bucket(i2)
+			sink ^= b1[0] ^ b2[0]
+		}
+	}
+	c.sink = sink
+}
+
+// probeGroups is the number of bucket ranges probes are sorted into
+const probeGroups = 1024
+
+// probes hashes keys and orders them by bucket range with a counting sort,
+// the result is valid until the next call.
+func (c *Cuckoo) probes(keys [][]byte) []probe {
+	n := len(keys)
+	if cap(c.batch) < 2*n {
+		c.batch = make([]probe, 2*n)
+	}
+	src, dst := c.batch[:n], c.batch[n:2*n]
+
+	var starts [probeGroups + 1]int
+	for k, key := range keys {
+		i, tag := c.generateIndexTagHash(key)
+		src[k] = probe{i: i, tag: tag, k: k}
+		starts[c.probeGroup(i)+1]++
+	}
+
+	for g := 1; g <= probeGroups; g++ {
+		starts[g] += starts[g-1]
+	}
+
+	for _, p := range src {
+		g := c.probeGroup(p.i)
+		dst[starts[g]] = p
+		starts[g]++
+	}
+
+	return dst
+}
+
+func (c *Cuckoo) probeGroup(i uint32) uint32 {
+	return uint32(uint64(i) * probeGroups / uint64(c.numBucket))
+}
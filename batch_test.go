@@ -0,0 +1,118 @@
+package cuckoo
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestCuckoo_Batch(t *testing.T) {
+	for _, table := range []Table{&singleTable{}, NewPackedTable()} {
+		testBatch(t, NewCuckooFilter(WithSeed(1), WithNumKeys(10000), WithBitsPerItem(13), WithTable(table)))
+	}
+}
+
+// run with -race, batches on separate filters share no state
+func TestCuckoo_BatchParallel(t *testing.T) {
+	keys := make([][]byte, 1000)
+	for k := range keys {
+		keys[k] = []byte(strconv.Itoa(k))
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		filter := NewCuckooFilter(WithSeed(1), WithNumKeys(2000))
+		filter.InsertBatch(keys)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out := make([]bool, len(keys))
+			for i := 0; i < 10; i++ {
+				filter.ContainBatch(keys, out)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func testBatch(t *testing.T, filter *Cuckoo) {
+
+	keys := make([][]byte, 5000)
+	for k := range keys {
+		keys[k] = []byte(strconv.Itoa(k))
+	}
+
+	for k, err := range filter.InsertBatch(keys) {
+		if err != nil {
+			t.Fatalf("add %v: %v", k, err)
+		}
+	}
+
+	if filter.Len() != uint32(len(keys)) {
+		t.Errorf("len %v, want %v", filter.Len(), len(keys))
+	}
+
+	lookups := make([][]byte, 2*len(keys))
+	for k := range lookups {
+		lookups[k] = []byte(strconv.Itoa(k))
+	}
+
+	out := make([]bool, len(lookups))
+	filter.ContainBatch(lookups, out)
+	for k, key := range lookups {
+		if out[k] != filter.Contain(key) {
+			t.Errorf("key %v: batch %v, single %v", k, out[k], !out[k])
+		}
+		if k < len(keys) && !out[k] {
+			t.Errorf("find %v fail", k)
+		}
+	}
+}
+
+// containFilter is shared by the lookup benchmarks, its table of 64MB does
+// not fit in the CPU caches.
+var containFilter *Cuckoo
+
+func benchmarkContain(b *testing.B, batch bool) {
+	if containFilter == nil {
+		const numKeys = 1 << 24
+		containFilter = NewCuckooFilter(WithSeed(1), WithNumKeys(numKeys))
+		keys := make([][]byte, numKeys)
+		for k := range keys {
+			keys[k] = []byte(strconv.Itoa(k))
+		}
+		containFilter.InsertBatch(keys)
+	}
+	filter := containFilter
+
+	// 10k keys per call, half of them in the filter, taken from a pool so
+	// the buckets of one call are not cached by the previous ones
+	const batchLen = 10000
+	pool := make([][]byte, 100*batchLen)
+	for k := range pool {
+		pool[k] = []byte(strconv.Itoa(k * 33))
+	}
+	out := make([]bool, batchLen)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lookups := pool[i%100*batchLen:][:batchLen]
+		if batch {
+			filter.ContainBatch(lookups, out)
+			continue
+		}
+
+		for k, key := range lookups {
+			out[k] = filter.Contain(key)
+		}
+	}
+	b.ReportMetric(float64(b.N*batchLen)/b.Elapsed().Seconds(), "keys/s")
+}
+
+func BenchmarkCuckoo_Contain(b *testing.B) {
+	benchmarkContain(b, false)
+}
+
+func BenchmarkCuckoo_ContainBatch(b *testing.B) {
+	benchmarkContain(b, true)
+}
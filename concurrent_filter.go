@@ -0,0 +1,127 @@
+package cuckoo
+
+import (
+	"sync"
+)
+
+// ConcurrentFilter is a Cuckoo filter safe for concurrent use.
+// Lookups run in parallel, Insert and Delete are serialized.
+type ConcurrentFilter struct {
+	mu sync.RWMutex
+	c  *Cuckoo
+
+	// a hash.Hash64 given by WithHash keeps state while hashing, lookups
+	// share it under hashMu. The built-in hashes need no lock.
+	hashMu    sync.Mutex
+	stateless bool
+}
+
+// NewConcurrentFilter takes the same options as NewCuckooFilter
+func NewConcurrentFilter(opts ...Option) *ConcurrentFilter {
+	c := NewCuckooFilter(opts...)
+	_, stateless := c.opt.hf.(sum64er)
+	return &ConcurrentFilter{
+		c:         c,
+		stateless: stateless,
+	}
+}
+
+func (f *ConcurrentFilter) Insert(x []byte) bool {
+	return f.Add(x) == nil
+}
+
+func (f *ConcurrentFilter) Add(x []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, tag := f.c.generateIndexTagHash(x)
+	return f.c.addTag(i, tag)
+}
+
+func (f *ConcurrentFilter) IsFull() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.c.IsFull()
+}
+
+func (f *ConcurrentFilter) Contain(item []byte) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	i, tag := f.indexTag(item)
+	return f.c.containTag(i, tag)
+}
+
+func (f *ConcurrentFilter) Delete(item []byte) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, tag := f.c.generateIndexTagHash(item)
+	return f.c.deleteTag(i, tag)
+}
+
+func (f *ConcurrentFilter) Len() uint32 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.c.Len()
+}
+
+func (f *ConcurrentFilter) LoadFactor() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.c.LoadFactor()
+}
+
+func (f *ConcurrentFilter) BitsPerItem() float64 {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.c.BitsPerItem()
+}
+
+func (f *ConcurrentFilter) MarshalBinary() ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.c.MarshalBinary()
+}
+
+func (f *ConcurrentFilter) UnmarshalBinary(data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.c.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	_, f.stateless = f.c.opt.hf.(sum64er)
+	return nil
+}
+
+// addHash, containHash and deleteHash take the hashes of an item computed
+// by the caller with hashItem and the filter's hash function.
+func (f *ConcurrentFilter) addHash(hv, hv2 uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, tag := f.c.indexTag(hv, hv2)
+	return f.c.addTag(i, tag)
+}
+
+func (f *ConcurrentFilter) containHash(hv, hv2 uint64) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	i, tag := f.c.indexTag(hv, hv2)
+	return f.c.containTag(i, tag)
+}
+
+func (f *ConcurrentFilter) deleteHash(hv, hv2 uint64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i, tag := f.c.indexTag(hv, hv2)
+	return f.c.deleteTag(i, tag)
+}
+
+// indexTag hashes an item for a lookup, the caller holds the read lock.
+func (f *ConcurrentFilter) indexTag(item []byte) (i uint32, tag uint64) {
+	if f.stateless {
+		return f.c.generateIndexTagHash(item)
+	}
+
+	f.hashMu.Lock()
+	defer f.hashMu.Unlock()
+	return f.c.generateIndexTagHash(item)
+}
@@ -0,0 +1,73 @@
+package cuckoo
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// run with -race
+func TestConcurrentFilter(t *testing.T) {
+	ts := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "default"},
+		{name: "seed", opts: []Option{WithSeed(1)}},
+		{name: "custom hash", opts: []Option{WithHash(fnv.New64a())}},
+		{name: "packed", opts: []Option{WithBitsPerItem(13), WithTable(NewPackedTable())}},
+	}
+
+	const workers, perWorker = 8, 500
+	for _, te := range ts {
+		filter := NewConcurrentFilter(append(te.opts, WithNumKeys(workers*perWorker*2))...)
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					bs := []byte(strconv.Itoa(w*perWorker + i))
+					if !filter.Insert(bs) {
+						t.Errorf("%v insert %s fail", te.name, bs)
+					}
+
+					if !filter.Contain(bs) {
+						t.Errorf("%v find %s fail", te.name, bs)
+					}
+				}
+			}(w)
+
+			// readers racing with the writers
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					filter.Contain([]byte(strconv.Itoa(i)))
+					filter.LoadFactor()
+				}
+			}()
+		}
+		wg.Wait()
+
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					bs := []byte(strconv.Itoa(w*perWorker + i))
+					if !filter.Delete(bs) {
+						t.Errorf("%v delete %s fail", te.name, bs)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		if filter.LoadFactor() != 0 {
+			t.Errorf("%v load factor %v after deleting all", te.name, filter.LoadFactor())
+		}
+	}
+}
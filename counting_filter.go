@@ -0,0 +1,245 @@
+package cuckoo
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxCount is the largest multiplicity a CountingFilter records
+const MaxCount = math.MaxUint8
+
+// countingVictim is a stashed fingerprint and its count
+type countingVictim struct {
+	index uint32
+	tag   uint64
+	n     uint8
+}
+
+// CountingFilter stores each fingerprint once with a counter next to it,
+// inserting an item again increments the counter, deleting it decrements.
+//
+// A counter saturates at MaxCount: adding an item already counted MaxCount
+// times returns ErrTooManyDuplicates and leaves the count unchanged, so
+// counts are always exact (up to fingerprint collisions).
+// Fingerprints live in a single table.
+type CountingFilter struct {
+	indexer
+	opt    Options
+	count  uint32
+	table  *singleTable
+	counts []uint8
+	stash  []countingVictim
+}
+
+// NewCountingFilter panics on invalid options, see NewCountingFilterE
+func NewCountingFilter(opts ...Option) *CountingFilter {
+	c, err := NewCountingFilterE(opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// NewCountingFilterE takes the options of NewCuckooFilterE except WithTable,
+// WithInsertStrategy(BreadthFirst) and WithFloodCallback, the error wraps
+// ErrInvalidOptions when one of them is given.
+func NewCountingFilterE(opts ...Option) (*CountingFilter, error) {
+	var opt Options
+	for _, o := range opts {
+		o(&opt)
+	}
+
+	switch {
+	case opt.table != nil:
+		return nil, fmt.Errorf("%w: counting filter keeps its own table, got %v", ErrInvalidOptions, opt.table)
+	case opt.strategy != RandomWalk:
+		return nil, fmt.Errorf("%w: counting filter supports the random walk only", ErrInvalidOptions)
+	case opt.flood != nil:
+		return nil, fmt.Errorf("%w: counting filter has no flood callback", ErrInvalidOptions)
+	}
+
+	opt.table = &singleTable{}
+	opt.apply()
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+
+	numBucket := opt.numBuckets()
+	opt.table.Init(numBucket, opt.tagsPerBucket, opt.bitsPerItem)
+	return &CountingFilter{
+		indexer: indexer{
+			numBucket:   numBucket,
+			bitsPerItem: opt.bitsPerItem,
+		},
+		opt:    opt,
+		table:  opt.table.(*singleTable),
+		counts: make([]uint8, numBucket*opt.tagsPerBucket),
+	}, nil
+}
+
+// Insert increments the count of x
+func (c *CountingFilter) Insert(x []byte) bool {
+	return c.Add(x) == nil
+}
+
+// Add increments the count of x, it returns ErrTooManyDuplicates when the
+// count is MaxCount and ErrFilterFull when x is new and the stash is full.
+func (c *CountingFilter) Add(x []byte) error {
+	i1, tag := c.indexTag(hashItem(x, c.opt.hf, c.wide()))
+	i2 := c.altIndex(i1, tag)
+
+	if slot, ok := c.find(i1, i2, tag); ok {
+		if c.counts[slot] == MaxCount {
+			return ErrTooManyDuplicates
+		}
+
+		c.counts[slot]++
+		return nil
+	}
+
+	if k := c.stashed(i1, i2, tag); k >= 0 {
+		if c.stash[k].n == MaxCount {
+			return ErrTooManyDuplicates
+		}
+
+		c.stash[k].n++
+		return nil
+	}
+
+	if c.IsFull() {
+		return ErrFilterFull
+	}
+
+	c.insert(i1, tag, 1)
+	return nil
+}
+
+// IsFull reports whether the stash is full, new items can not be added
+// until an item is deleted.
+func (c *CountingFilter) IsFull() bool {
+	return uint32(len(c.stash)) >= c.opt.stashSize
+}
+
+// Count returns how many times item was inserted and not deleted
+func (c *CountingFilter) Count(item []byte) uint32 {
+	i1, tag := c.indexTag(hashItem(item, c.opt.hf, c.wide()))
+	i2 := c.altIndex(i1, tag)
+
+	if slot, ok := c.find(i1, i2, tag); ok {
+		return uint32(c.counts[slot])
+	}
+
+	if k := c.stashed(i1, i2, tag); k >= 0 {
+		return uint32(c.stash[k].n)
+	}
+
+	return 0
+}
+
+func (c *CountingFilter) Contain(item []byte) bool {
+	return c.Count(item) > 0
+}
+
+// Delete decrements the count of item, the fingerprint is removed when the
+// count drops to zero.
+func (c *CountingFilter) Delete(item []byte) bool {
+	i1, tag := c.indexTag(hashItem(item, c.opt.hf, c.wide()))
+	i2 := c.altIndex(i1, tag)
+
+	if k := c.stashed(i1, i2, tag); k >= 0 {
+		c.stash[k].n--
+		if c.stash[k].n == 0 {
+			c.stash = append(c.stash[:k], c.stash[k+1:]...)
+		}
+		return true
+	}
+
+	slot, ok := c.find(i1, i2, tag)
+	if !ok {
+		return false
+	}
+
+	c.counts[slot]--
+	if c.counts[slot] > 0 {
+		return true
+	}
+
+	c.table.writeTag(slot/c.opt.tagsPerBucket, slot%c.opt.tagsPerBucket, 0)
+	c.count--
+	if len(c.stash) == 0 {
+		return true
+	}
+
+	// reinsert the oldest stashed fingerprint into the freed slot
+	v := c.stash[0]
+	c.stash = append(c.stash[:0], c.stash[1:]...)
+	c.insert(v.index, v.tag, v.n)
+	return true
+}
+
+// Len returns the number of distinct fingerprints stored in the table and
+// the stash
+func (c *CountingFilter) Len() uint32 {
+	return c.count + uint32(len(c.stash))
+}
+
+// LoadFactor counts the stashed fingerprints and the stash slots
+func (c *CountingFilter) LoadFactor() float64 {
+	return 1.0 * float64(c.Len()) / (float64(c.table.SizeInTags()) + float64(c.opt.stashSize))
+}
+
+// stashed returns the stash position of tag in bucket i1 or i2, -1 if it is
+// not stashed.
+func (c *CountingFilter) stashed(i1, i2 uint32, tag uint64) int {
+	for k, v := range c.stash {
+		if v.tag == tag && (v.index == i1 || v.index == i2) {
+			return k
+		}
+	}
+
+	return -1
+}
+
+// find returns the slot holding tag in bucket i1 or i2
+func (c *CountingFilter) find(i1, i2 uint32, tag uint64) (uint32, bool) {
+	for _, i := range [2]uint32{i1, i2} {
+		var j uint32
+		for j = 0; j < c.opt.tagsPerBucket; j++ {
+			if c.table.readTag(i, j) == tag {
+				return i*c.opt.tagsPerBucket + j, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// insert places tag with count n, a kicked out fingerprint takes its counter
+// along.
+func (c *CountingFilter) insert(i uint32, tag uint64, n uint8) {
+	b := c.opt.tagsPerBucket
+	for cnt := 0; cnt < c.opt.kicks; cnt++ {
+		var j uint32
+		for j = 0; j < b; j++ {
+			if c.table.readTag(i, j) == 0 {
+				c.table.writeTag(i, j, tag)
+				c.counts[i*b+j] = n
+				c.count++
+				return
+			}
+		}
+
+		r := uint32(randIntn(c.opt.rand, int(b)))
+		oldTag := c.table.readTag(i, r)
+		c.table.writeTag(i, r, tag)
+		tag, n, c.counts[i*b+r] = oldTag, c.counts[i*b+r], n
+		i = c.altIndex(i, tag)
+	}
+
+	c.stash = append(c.stash, countingVictim{
+		index: i,
+		tag:   tag,
+		n:     n,
+	})
+}
@@ -0,0 +1,137 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestCountingFilter(t *testing.T) {
+	const n = 2000
+	filter := NewCountingFilter(WithSeed(1), WithNumKeys(n))
+
+	// item i is inserted i%5+1 times
+	for k := 0; k < 5; k++ {
+		for i := 0; i < n; i++ {
+			if i%5 >= k && !filter.Insert([]byte(strconv.Itoa(i))) {
+				t.Fatalf("insert %v fail", i)
+			}
+		}
+	}
+
+	if filter.Len() != n {
+		t.Errorf("len %v, want %v", filter.Len(), n)
+	}
+
+	for i := 0; i < n; i++ {
+		if got := filter.Count([]byte(strconv.Itoa(i))); got != uint32(i%5+1) {
+			t.Errorf("count %v = %v, want %v", i, got, i%5+1)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		bs := []byte(strconv.Itoa(i))
+		if !filter.Delete(bs) {
+			t.Errorf("delete %v fail", i)
+		}
+
+		if got := filter.Count(bs); got != uint32(i%5) {
+			t.Errorf("count %v after delete = %v, want %v", i, got, i%5)
+		}
+
+		if filter.Contain(bs) != (i%5 > 0) {
+			t.Errorf("contain %v after delete", i)
+		}
+	}
+}
+
+func TestCountingFilter_Overflow(t *testing.T) {
+	filter := NewCountingFilter(WithSeed(1), WithNumKeys(100))
+	item := []byte("item")
+	for i := 0; i < MaxCount; i++ {
+		if !filter.Insert(item) {
+			t.Fatalf("insert %v fail", i)
+		}
+	}
+
+	if filter.Insert(item) {
+		t.Errorf("insert above MaxCount should fail")
+	}
+
+	if got := filter.Count(item); got != MaxCount {
+		t.Errorf("count %v, want %v", got, MaxCount)
+	}
+
+	if filter.Len() != 1 {
+		t.Errorf("len %v, want 1", filter.Len())
+	}
+
+	for i := 0; i < MaxCount; i++ {
+		if !filter.Delete(item) {
+			t.Fatalf("delete %v fail", i)
+		}
+	}
+
+	if filter.Contain(item) || filter.Delete(item) {
+		t.Errorf("item still counted after deleting all")
+	}
+}
+
+func TestCountingFilter_Full(t *testing.T) {
+	for _, stashSize := range []uint32{1, 8} {
+		// overfill so that kicked out fingerprints carry their counters and
+		// the stash fills up
+		filter := NewCountingFilter(WithSeed(1), WithNumKeys(64), WithBitsPerItem(16), WithStashSize(stashSize))
+
+		var inserted int
+		for ; inserted < 1000; inserted++ {
+			bs := []byte(strconv.Itoa(inserted))
+			if !filter.Insert(bs) || !filter.Insert(bs) {
+				break
+			}
+		}
+
+		if uint32(len(filter.stash)) != stashSize || !filter.IsFull() {
+			t.Fatalf("stash len %v, want %v", len(filter.stash), stashSize)
+		}
+
+		if lf := filter.LoadFactor(); lf != float64(filter.Len())/float64(filter.table.SizeInTags()+stashSize) {
+			t.Errorf("load factor %v does not count the stash", lf)
+		}
+
+		for i := 0; i < inserted; i++ {
+			if got := filter.Count([]byte(strconv.Itoa(i))); got != 2 {
+				t.Errorf("count %v = %v, want 2", i, got)
+			}
+		}
+
+		// each delete of a last count frees a slot for a stashed fingerprint
+		for i := 0; i < inserted; i++ {
+			bs := []byte(strconv.Itoa(i))
+			if !filter.Delete(bs) || !filter.Delete(bs) {
+				t.Errorf("delete %v fail", i)
+			}
+		}
+
+		if filter.Len() != 0 || len(filter.stash) != 0 {
+			t.Errorf("len %v stash %v after deleting all", filter.Len(), len(filter.stash))
+		}
+	}
+}
+
+func TestNewCountingFilterE(t *testing.T) {
+	for k, opts := range [][]Option{
+		{WithTable(NewPackedTable())},
+		{WithInsertStrategy(BreadthFirst)},
+		{WithFloodCallback(1, func(i1, i2, n uint32) {})},
+		{WithBitsPerItem(65)},
+	} {
+		if _, err := NewCountingFilterE(opts...); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("case %v expect ErrInvalidOptions, got %v", k, err)
+		}
+	}
+
+	if _, err := NewCountingFilterE(WithSeed(1), WithStashSize(4), WithExactCapacity()); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,18 +1,47 @@
 package cuckoo
 
 import (
+	"errors"
+	"fmt"
 	"hash"
-	"hash/maphash"
+	"math/rand"
+)
+
+var (
+	// ErrFilterFull is returned when the stash is full, nothing can be
+	// inserted until an item is deleted.
+	ErrFilterFull = errors.New("cuckoo: filter is full")
+
+	// ErrTooManyDuplicates is returned when both buckets of an item are
+	// filled with its own fingerprint.
+	ErrTooManyDuplicates = errors.New("cuckoo: too many duplicates")
+
+	// ErrNoPath is returned by TryAdd when no free slot is within reach,
+	// the filter is left unchanged.
+	ErrNoPath = errors.New("cuckoo: no path to a free slot")
+
+	// ErrInvalidOptions is returned by NewCuckooFilterE for options the
+	// filter or its table can not work with.
+	ErrInvalidOptions = errors.New("cuckoo: invalid options")
 )
 
 // Options cuckoo options
 type Options struct {
 	hf            hash.Hash64
+	hashKind      uint8
+	seed          uint64
 	kicks         int
 	numKeys       uint32
 	tagsPerBucket uint32
 	bitsPerItem   uint32
 	table         Table
+	rand          *rand.Rand
+	strategy      InsertStrategy
+	stashSize     uint32
+	exact         bool
+
+	floodThreshold uint32
+	flood          func(i1, i2, n uint32)
 }
 
 func (o *Options) apply() {
@@ -21,9 +50,8 @@ func (o *Options) apply() {
 	}
 
 	if o.hf == nil {
-		hf := &maphash.Hash{}
-		hf.SetSeed(hf.Seed())
-		o.hf = hf
+		o.hf = newProcessHash()
+		o.hashKind = hashProcess
 	}
 
 	if o.table == nil {
@@ -34,6 +62,10 @@ func (o *Options) apply() {
 		o.tagsPerBucket = 4
 	}
 
+	if o.stashSize == 0 {
+		o.stashSize = 1
+	}
+
 	if o.numKeys == 0 {
 		o.numKeys = 10000
 	}
@@ -43,12 +75,126 @@ func (o *Options) apply() {
 	}
 }
 
+// validate checks applied options against the filter and the table limits
+func (o *Options) validate() error {
+	if o.kicks < 0 {
+		return fmt.Errorf("%w: negative kick count %v", ErrInvalidOptions, o.kicks)
+	}
+
+	if o.strategy > BreadthFirst {
+		return fmt.Errorf("%w: unknown insert strategy %v", ErrInvalidOptions, o.strategy)
+	}
+
+	if err := o.table.Limits().Check(o.tagsPerBucket, o.bitsPerItem); err != nil {
+		return fmt.Errorf("%w: %v %v", ErrInvalidOptions, o.table, err)
+	}
+
+	// the tables count the bits of a bucket in uint32
+	if uint64(o.tagsPerBucket)*uint64(o.bitsPerItem) > 1<<31-1 {
+		return fmt.Errorf("%w: %v tags of %v bits do not fit in a bucket", ErrInvalidOptions, o.tagsPerBucket, o.bitsPerItem)
+	}
+
+	if o.numKeys/o.tagsPerBucket > 1<<30 || uint64(o.numBuckets())*uint64(o.tagsPerBucket) > 1<<32-1 {
+		return fmt.Errorf("%w: %v keys do not fit in %v tags per bucket", ErrInvalidOptions, o.numKeys, o.tagsPerBucket)
+	}
+
+	return nil
+}
+
+// numBuckets returns the power of two bucket count for numKeys, or the
+// smallest count that holds numKeys at a load of 0.96 with WithExactCapacity.
+func (o *Options) numBuckets() uint32 {
+	if o.exact {
+		tags := uint64(o.tagsPerBucket) * 96
+		return uint32((uint64(o.numKeys)*100 + tags - 1) / tags)
+	}
+
+	numBucket := upperPow32(o.numKeys / o.tagsPerBucket)
+	frac := float64(o.numKeys) / float64(numBucket*o.tagsPerBucket)
+	if frac > 0.96 {
+		numBucket <<= 1
+	}
+
+	return numBucket
+}
+
 type Option func(options *Options)
 
-// WithHash
+// WithHash uses hf to hash items, a marshaled filter records the hash of a
+// fixed message by hf and loads into a receiver given an equal hash.
 func WithHash(hf hash.Hash64) Option {
 	return func(options *Options) {
 		options.hf = hf
+		options.hashKind = hashCustom
+		options.seed = hash64(hashCheckMessage, hf)
+	}
+}
+
+// WithSeed use the built-in seeded hash, filters with the same seed index
+// items the same way on every process and machine, so they can be shared
+// and persisted.
+func WithSeed(seed uint64) Option {
+	return func(options *Options) {
+		options.hf = newSeededHash(seed)
+		options.hashKind = hashSeeded
+		options.seed = seed
+	}
+}
+
+// WithRand picks the slots kicked out on insert from src instead of the
+// global math/rand source, the same src seed and insertion order give the
+// same filter. src is not safe for concurrent use and must not be shared
+// with other filters.
+func WithRand(src rand.Source) Option {
+	return func(options *Options) {
+		options.rand = rand.New(src)
+	}
+}
+
+// InsertStrategy selects how Add makes room when both buckets of an item
+// are full.
+type InsertStrategy uint8
+
+const (
+	// RandomWalk kicks out random fingerprints, up to WithKickCount times
+	RandomWalk InsertStrategy = iota
+	// BreadthFirst searches for a short chain of moves to a free slot
+	// before moving anything, like TryAdd, and falls back to RandomWalk
+	// when there is none. On one goroutine it reads more buckets than the
+	// random walk and adds are slower, but an add moves at most
+	// pathMaxDepth tags.
+	BreadthFirst
+)
+
+// WithInsertStrategy sets the InsertStrategy, RandomWalk by default
+func WithInsertStrategy(s InsertStrategy) Option {
+	return func(options *Options) {
+		options.strategy = s
+	}
+}
+
+// WithKey uses SipHash-2-4 with a secret key, items that collide can not be
+// crafted without the key. MarshalBinary records a check value of the key,
+// the filter loads into a receiver built WithKey with the same key.
+func WithKey(key [16]byte) Option {
+	return func(options *Options) {
+		h := newSipHash(key)
+		options.hf = h
+		options.hashKind = hashKeyed
+		options.seed = h.check()
+	}
+}
+
+// WithFloodCallback calls fn when adds to the buckets i1 and i2 have run out
+// of kicks or been rejected with ErrFilterFull or ErrTooManyDuplicates
+// n >= threshold times, a sign of items crafted to share buckets. fn runs
+// on the inserting goroutine, under the lock of a ConcurrentFilter or
+// ShardedFilter shard. Use it with WithKey, which makes such items hard to
+// find.
+func WithFloodCallback(threshold uint32, fn func(i1, i2, n uint32)) Option {
+	return func(options *Options) {
+		options.floodThreshold = threshold
+		options.flood = fn
 	}
 }
 
@@ -77,6 +223,24 @@ func WithTagsPerBucket(n uint32) Option {
 	}
 }
 
+// WithStashSize sets how many fingerprints that found no slot are kept aside
+// before Add returns ErrFilterFull, 1 by default. Lookups check every
+// stashed fingerprint.
+func WithStashSize(n uint32) Option {
+	return func(options *Options) {
+		options.stashSize = n
+	}
+}
+
+// WithExactCapacity sizes the table to numKeys instead of rounding the
+// bucket count up to a power of two, which can double the memory.
+// Other bucket counts index with a multiply instead of a mask.
+func WithExactCapacity() Option {
+	return func(options *Options) {
+		options.exact = true
+	}
+}
+
 // WithBitsPerItem per item has bits count
 func WithBitsPerItem(n uint32) Option {
 	return func(options *Options) {
@@ -84,41 +248,73 @@ func WithBitsPerItem(n uint32) Option {
 	}
 }
 
+// victim is a fingerprint kept in the stash, index is one of its buckets
 type victim struct {
 	index uint32
-	tag   uint32
-	used  bool
+	tag   uint64
 }
 
-type Cuckoo struct {
-	opt         Options
-	count       uint32
+// indexer maps item hashes to buckets and tags
+type indexer struct {
 	numBucket   uint32
 	bitsPerItem uint32
-	table       Table
-	victim      victim
 }
 
-// NewCuckooFilter
+type Cuckoo struct {
+	indexer
+	opt   Options
+	count uint32
+	table Table
+	stash []victim
+
+	// reused by pathInsert, the batch calls and to read buckets, a local
+	// buffer would escape to the heap through the Table interface
+	path  []pathNode
+	batch []probe
+	tags  []uint64
+	// key buffer of the Uint64 methods
+	key [8]byte
+	// keeps the loads of touch from being optimized away
+	sink byte
+
+	// kick exhaustions per bucket pair, see WithFloodCallback
+	exhausted floodPairs
+}
+
+// NewCuckooFilter panics on invalid options, see NewCuckooFilterE
 func NewCuckooFilter(opts ...Option) *Cuckoo {
+	c, err := NewCuckooFilterE(opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return c
+}
+
+// NewCuckooFilterE returns an error wrapping ErrInvalidOptions when the
+// options are out of the Limits of the table.
+func NewCuckooFilterE(opts ...Option) (*Cuckoo, error) {
 	var opt Options
 	for _, o := range opts {
 		o(&opt)
 	}
 	opt.apply()
-	numBucket := upperPow32(opt.numKeys / opt.tagsPerBucket)
-	frac := float64(opt.numKeys) / float64(numBucket*opt.tagsPerBucket)
-	if frac > 0.96 {
-		numBucket <<= 1
+	if err := opt.validate(); err != nil {
+		return nil, err
 	}
+
+	numBucket := opt.numBuckets()
 	opt.table.Init(numBucket, opt.tagsPerBucket, opt.bitsPerItem)
+	opt.table.SetRand(opt.rand)
 	return &Cuckoo{
-		opt:         opt,
-		table:       opt.table,
-		numBucket:   numBucket,
-		bitsPerItem: opt.bitsPerItem,
-		count:       0,
-	}
+		indexer: indexer{
+			numBucket:   numBucket,
+			bitsPerItem: opt.bitsPerItem,
+		},
+		opt:   opt,
+		table: opt.table,
+		count: 0,
+	}, nil
 }
 
 /*
@@ -141,12 +337,22 @@ func NewCuckooFilter(opts ...Option) *Cuckoo {
 	return Failure;
 */
 func (c *Cuckoo) Insert(x []byte) bool {
-	if c.victim.used {
-		return false
-	}
+	return c.Add(x) == nil
+}
 
+// Add inserts x, it returns ErrFilterFull or ErrTooManyDuplicates when x
+// can not be inserted.
+// Add may succeed by moving another fingerprint to the stash, IsFull reports
+// a full stash and later adds fail until an item is deleted.
+func (c *Cuckoo) Add(x []byte) error {
 	i, tag := c.generateIndexTagHash(x)
-	return c.insert(i, tag)
+	return c.addTag(i, tag)
+}
+
+// IsFull reports whether the stash is full, Add returns ErrFilterFull
+// until an item is deleted.
+func (c *Cuckoo) IsFull() bool {
+	return uint32(len(c.stash)) >= c.opt.stashSize
 }
 
 /*
@@ -159,29 +365,81 @@ func (c *Cuckoo) Insert(x []byte) bool {
 */
 func (c *Cuckoo) Contain(item []byte) bool {
 	i1, tag := c.generateIndexTagHash(item)
+	return c.containTag(i1, tag)
+}
+
+func (c *Cuckoo) Delete(item []byte) bool {
+	i1, tag := c.generateIndexTagHash(item)
+	return c.deleteTag(i1, tag)
+}
+
+func (c *Cuckoo) addTag(i uint32, tag uint64) error {
+	if c.IsFull() {
+		c.exhaust(i, tag)
+		return ErrFilterFull
+	}
+
+	if c.duplicates(i, tag) {
+		c.exhaust(i, tag)
+		return ErrTooManyDuplicates
+	}
+
+	if !c.place(i, tag) {
+		c.exhaust(i, tag)
+	}
+	return nil
+}
+
+// duplicates reports whether both buckets of tag are full of tag
+func (c *Cuckoo) duplicates(i1 uint32, tag uint64) bool {
+	tags := c.table.ReadBucket(i1, c.tags[:0])
+	if i2 := c.altIndex(i1, tag); i2 != i1 {
+		tags = c.table.ReadBucket(i2, tags)
+	}
+	c.tags = tags
+
+	for _, t := range tags {
+		if t != tag {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containTag only reads the filter, it is safe to run concurrently with
+// other lookups.
+func (c *Cuckoo) containTag(i1 uint32, tag uint64) bool {
 	i2 := c.altIndex(i1, tag)
 
 	if i1 != c.altIndex(i2, tag) {
 		panic("what happened before")
 	}
 
-	if c.victim.used &&
-		c.victim.tag == tag &&
-		(c.victim.index == i1 || c.victim.index == i2) {
+	if c.stashed(i1, i2, tag) >= 0 {
 		return true
 	}
 
 	return c.table.Find(i1, tag) || c.table.Find(i2, tag)
 }
 
-func (c *Cuckoo) Delete(item []byte) bool {
-	i1, tag := c.generateIndexTagHash(item)
+// stashed returns the stash position of tag in bucket i1 or i2, -1 if it is
+// not stashed.
+func (c *Cuckoo) stashed(i1, i2 uint32, tag uint64) int {
+	for k, v := range c.stash {
+		if v.tag == tag && (v.index == i1 || v.index == i2) {
+			return k
+		}
+	}
+
+	return -1
+}
+
+func (c *Cuckoo) deleteTag(i1 uint32, tag uint64) bool {
 	i2 := c.altIndex(i1, tag)
 
-	if c.victim.used &&
-		c.victim.tag == tag &&
-		(c.victim.index == i1 || c.victim.index == i2) {
-		c.victim.used = false
+	if k := c.stashed(i1, i2, tag); k >= 0 {
+		c.stash = append(c.stash[:k], c.stash[k+1:]...)
 		return true
 	}
 
@@ -191,16 +449,19 @@ func (c *Cuckoo) Delete(item []byte) bool {
 
 	c.count--
 	// delete success
-	if !c.victim.used {
+	if len(c.stash) == 0 {
 		return true
 	}
 
-	// reinsert victim
-	c.insert(c.victim.index, c.victim.tag)
+	// reinsert the oldest stashed fingerprint into the freed slot
+	v := c.stash[0]
+	c.stash = append(c.stash[:0], c.stash[1:]...)
+	c.place(v.index, v.tag)
 	return true
 }
 
-func (c *Cuckoo) insert(i uint32, tag uint32) bool {
+// insert returns false when it ran out of kicks and stashed a tag
+func (c *Cuckoo) insert(i uint32, tag uint64) bool {
 	var ok bool
 	for cnt := 0; cnt < c.opt.kicks; cnt++ {
 		kickout := cnt > 0
@@ -213,38 +474,163 @@ func (c *Cuckoo) insert(i uint32, tag uint32) bool {
 		i = c.altIndex(i, tag)
 	}
 
-	c.victim = victim{
+	c.stash = append(c.stash, victim{
 		index: i,
 		tag:   tag,
-		used:  true,
+	})
+	return false
+}
+
+// floodMaxPairs bounds the bucket pairs exhaust counts
+const floodMaxPairs = 1024
+
+// floodPair counts the exhaustions of a bucket pair, every exhaustion sets
+// ref and the clock hand of floodPairs clears it.
+type floodPair struct {
+	pair [2]uint32
+	n    uint32
+	ref  bool
+}
+
+// floodPairs counts exhaustions per bucket pair in floodMaxPairs slots. A
+// new pair takes the first slot the clock hand finds with ref cleared, a
+// pair under attack sets ref again before the hand comes back and keeps its
+// count. Each step of the hand clears a ref set by an exhaustion, so adding
+// a pair takes amortized constant time.
+type floodPairs struct {
+	slots map[[2]uint32]int
+	pairs []floodPair
+	hand  int
+}
+
+// add counts an exhaustion of pair and returns its count
+func (f *floodPairs) add(pair [2]uint32) uint32 {
+	if k, ok := f.slots[pair]; ok {
+		p := &f.pairs[k]
+		p.n++
+		p.ref = true
+		return p.n
+	}
+
+	if f.slots == nil {
+		f.slots = make(map[[2]uint32]int, floodMaxPairs)
+	}
+
+	k := len(f.pairs)
+	if k < floodMaxPairs {
+		f.pairs = append(f.pairs, floodPair{})
+	} else {
+		for f.pairs[f.hand].ref {
+			f.pairs[f.hand].ref = false
+			f.hand = (f.hand + 1) % floodMaxPairs
+		}
+		k = f.hand
+		f.hand = (f.hand + 1) % floodMaxPairs
+		delete(f.slots, f.pairs[k].pair)
+	}
+
+	f.pairs[k] = floodPair{pair: pair, n: 1}
+	f.slots[pair] = k
+	return 1
+}
+
+// count returns the exhaustions of pair
+func (f *floodPairs) count(pair [2]uint32) uint32 {
+	if k, ok := f.slots[pair]; ok {
+		return f.pairs[k].n
+	}
+
+	return 0
+}
+
+// exhaust counts an add of tag to bucket i1 that ran out of kicks or was
+// rejected
+func (c *Cuckoo) exhaust(i1 uint32, tag uint64) {
+	if c.opt.flood == nil {
+		return
+	}
+
+	i2 := c.altIndex(i1, tag)
+	if i1 > i2 {
+		i1, i2 = i2, i1
+	}
+	if n := c.exhausted.add([2]uint32{i1, i2}); n >= c.opt.floodThreshold {
+		c.opt.flood(i1, i2, n)
 	}
-	return true
 }
 
+// Len returns the number of items stored in the table and the stash
+func (c *Cuckoo) Len() uint32 {
+	return c.count + uint32(len(c.stash))
+}
+
+// LoadFactor counts the stashed fingerprints and the stash slots
 func (c *Cuckoo) LoadFactor() float64 {
-	return 1.0 * float64(c.count) / float64(c.table.SizeInTags())
+	return 1.0 * float64(c.Len()) / (float64(c.table.SizeInTags()) + float64(c.opt.stashSize))
+}
+
+// tableBits returns the memory of the table in bits
+func (c *Cuckoo) tableBits() uint64 {
+	if n, ok := tableBytes(c.table.String(), c.numBucket, c.opt.tagsPerBucket, c.bitsPerItem); ok {
+		return 8 * n
+	}
+
+	return uint64(c.table.SizeInTags()) * uint64(c.bitsPerItem)
 }
 
 func (c *Cuckoo) BitsPerItem() float64 {
 	return 8.0 * float64(c.table.SizeInTags()) / float64(c.count)
 }
 
-func (c *Cuckoo) generateIndexTagHash(item []byte) (i, tag uint32) {
-	hs := hash64(item, c.opt.hf)
-	return c.indexHash(uint32(hs >> 32)), c.tagHash(uint32(hs))
+func (c *Cuckoo) generateIndexTagHash(item []byte) (i uint32, tag uint64) {
+	return c.indexTag(hashItem(item, c.opt.hf, c.wide()))
+}
+
+// wide reports whether tags take more bits than the low half of the item
+// hash, they are then taken from a second hash so that index and tag bits
+// stay independent.
+func (x indexer) wide() bool {
+	return x.bitsPerItem > 32
+}
+
+// indexTag takes the hashes returned by hashItem
+func (x indexer) indexTag(hs, hs2 uint64) (i uint32, tag uint64) {
+	if x.wide() {
+		return x.indexHash(uint32(hs >> 32)), x.tagHash(hs2)
+	}
+
+	return x.indexHash(uint32(hs >> 32)), x.tagHash(uint64(uint32(hs)))
 }
 
-func (c *Cuckoo) indexHash(hv uint32) uint32 {
-	return hv & (c.numBucket - 1)
+func (x indexer) pow2() bool {
+	return x.numBucket&(x.numBucket-1) == 0
 }
 
-func (c *Cuckoo) altIndex(i, tag uint32) uint32 {
+func (x indexer) indexHash(hv uint32) uint32 {
+	if x.pow2() {
+		return hv & (x.numBucket - 1)
+	}
+
+	// maps hv to [0, numBucket) without a division
+	return uint32(uint64(hv) * uint64(x.numBucket) >> 32)
+}
+
+// altIndex returns the other bucket of tag, i2 = i1 ⊕ hash(f) for power of
+// two bucket counts and i2 = (hash(f) - i1) mod numBucket for the others,
+// both give back i1 for i2.
+func (x indexer) altIndex(i uint32, tag uint64) uint32 {
 	// 0x5bd1e995 is the hash constant from MurmurHash2
-	return c.indexHash(i ^ (tag * 0x5bd1e995))
+	h := uint32(tag^tag>>32) * 0x5bd1e995
+	if x.pow2() {
+		return (i ^ h) & (x.numBucket - 1)
+	}
+
+	n := uint64(x.numBucket)
+	return uint32((uint64(h)%n + n - uint64(i)) % n)
 }
 
-func (c *Cuckoo) tagHash(hv uint32) uint32 {
-	tag := hv & ((1 << c.bitsPerItem) - 1)
+func (x indexer) tagHash(hv uint64) uint64 {
+	tag := hv & ((1 << x.bitsPerItem) - 1)
 	if tag == 0 {
 		tag = 1
 	}
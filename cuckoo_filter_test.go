@@ -1,7 +1,10 @@
 package cuckoo
 
 import (
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math/rand"
 	"strconv"
 	"testing"
 )
@@ -191,3 +194,251 @@ func TestCuckoo_Delete(t *testing.T) {
 		}
 	}
 }
+
+func TestCuckoo_Add(t *testing.T) {
+	filter := NewCuckooFilter(WithSeed(1), WithNumKeys(1000))
+	item := []byte("item")
+	i1, tag := filter.generateIndexTagHash(item)
+	copies := 2 * 4
+	if filter.altIndex(i1, tag) == i1 {
+		copies = 4
+	}
+
+	for i := 0; i < copies; i++ {
+		if err := filter.Add(item); err != nil {
+			t.Fatalf("add copy %v: %v", i, err)
+		}
+	}
+
+	if err := filter.Add(item); !errors.Is(err, ErrTooManyDuplicates) {
+		t.Errorf("expect ErrTooManyDuplicates, got %v", err)
+	}
+
+	if filter.IsFull() {
+		t.Errorf("duplicates must not fill the filter")
+	}
+
+	filter = NewCuckooFilter(WithSeed(1), WithNumKeys(64))
+	var i int
+	for ; !filter.IsFull(); i++ {
+		if err := filter.Add([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("add %v: %v", i, err)
+		}
+	}
+
+	if err := filter.Add([]byte(strconv.Itoa(i))); !errors.Is(err, ErrFilterFull) {
+		t.Errorf("expect ErrFilterFull, got %v", err)
+	}
+
+	if filter.Insert([]byte(strconv.Itoa(i))) {
+		t.Errorf("insert into a full filter")
+	}
+}
+
+func TestNewCuckooFilterE(t *testing.T) {
+	ts := []struct {
+		opts  []Option
+		valid bool
+	}{
+		{opts: nil, valid: true},
+		{opts: []Option{WithBitsPerItem(2), WithTagsPerBucket(8)}, valid: true},
+		{opts: []Option{WithBitsPerItem(13)}, valid: true},
+		{opts: []Option{WithBitsPerItem(65)}},
+		{opts: []Option{WithBitsPerItem(13), WithTable(NewPackedTable())}, valid: true},
+		{opts: []Option{WithBitsPerItem(12), WithTable(NewPackedTable())}, valid: true},
+		{opts: []Option{WithBitsPerItem(4), WithTable(NewPackedTable())}},
+		{opts: []Option{WithBitsPerItem(33), WithTable(NewPackedTable())}},
+		{opts: []Option{WithBitsPerItem(13), WithTagsPerBucket(8), WithTable(NewPackedTable())}, valid: true},
+		{opts: []Option{WithBitsPerItem(13), WithTagsPerBucket(2), WithTable(NewPackedTable())}},
+		{opts: []Option{WithKickCount(-1)}},
+		{opts: []Option{WithNumKeys(1 << 31), WithTagsPerBucket(1)}},
+		{opts: []Option{WithBitsPerItem(64), WithTagsPerBucket(1 << 10)}, valid: true},
+		{opts: []Option{WithBitsPerItem(64), WithTagsPerBucket(1 << 26)}},
+	}
+
+	for k, te := range ts {
+		filter, err := NewCuckooFilterE(te.opts...)
+		if !te.valid {
+			if !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("case %v expect ErrInvalidOptions, got %v", k, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("case %v: %v", k, err)
+			continue
+		}
+
+		for i := 0; i < 100; i++ {
+			if err := filter.Add([]byte(strconv.Itoa(i))); err != nil {
+				t.Errorf("case %v add %v: %v", k, i, err)
+			}
+		}
+
+		for i := 0; i < 100; i++ {
+			bs := []byte(strconv.Itoa(i))
+			if !filter.Contain(bs) || !filter.Delete(bs) {
+				t.Errorf("case %v find or delete %v fail", k, i)
+			}
+		}
+
+		if filter.Len() != 0 {
+			t.Errorf("case %v len %v after deleting all", k, filter.Len())
+		}
+	}
+}
+
+func TestCuckoo_WideTags(t *testing.T) {
+	for _, bits := range []uint32{33, 48, 64} {
+		for _, opt := range []Option{WithSeed(1), WithHash(fnv.New64a()), nil} {
+			opts := []Option{WithNumKeys(20000), WithBitsPerItem(bits)}
+			if opt != nil {
+				opts = append(opts, opt)
+			}
+			filter := NewCuckooFilter(opts...)
+
+			for i := 0; i < 15000; i++ {
+				if err := filter.Add([]byte(strconv.Itoa(i))); err != nil {
+					t.Fatalf("bits %v add %v: %v", bits, i, err)
+				}
+			}
+
+			for i := 0; i < 15000; i++ {
+				if !filter.Contain([]byte(strconv.Itoa(i))) {
+					t.Errorf("bits %v find %v fail", bits, i)
+				}
+			}
+
+			for i := 15000; i < 100000; i++ {
+				if filter.Contain([]byte(strconv.Itoa(i))) {
+					t.Errorf("bits %v false positive %v", bits, i)
+				}
+			}
+
+			// the tag must not be derived from the index bits
+			hv, hv2 := hashItem([]byte("cuckoo"), filter.opt.hf, true)
+			if _, tag := filter.indexTag(hv, hv2); tag != filter.tagHash(hv2) || hv2 == hv {
+				t.Errorf("bits %v tag not taken from the second hash", bits)
+			}
+		}
+	}
+}
+
+func TestWithRand(t *testing.T) {
+	tables := []func() Table{
+		func() Table { return &singleTable{} },
+		func() Table { return NewPackedTable() },
+	}
+	for _, newTable := range tables {
+		var dumps [2][]byte
+		for k := range dumps {
+			filter := NewCuckooFilter(WithSeed(1), WithRand(rand.NewSource(42)), WithNumKeys(4000), WithBitsPerItem(13), WithTable(newTable()))
+			// high load so items get kicked out
+			for i := 0; i < 3900; i++ {
+				filter.Insert([]byte(strconv.Itoa(i)))
+			}
+
+			var err error
+			if dumps[k], err = filter.MarshalBinary(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if string(dumps[0]) != string(dumps[1]) {
+			t.Errorf("%v: filters built with the same source differ", newTable())
+		}
+	}
+}
+
+func TestCuckoo_Stash(t *testing.T) {
+	filter := NewCuckooFilter(WithSeed(1), WithNumKeys(64), WithBitsPerItem(16), WithStashSize(4))
+
+	var inserted int
+	for ; !filter.IsFull(); inserted++ {
+		if err := filter.Add([]byte(strconv.Itoa(inserted))); err != nil {
+			t.Fatalf("add %v: %v", inserted, err)
+		}
+	}
+
+	if len(filter.stash) != 4 {
+		t.Fatalf("stash len %v, want 4", len(filter.stash))
+	}
+
+	if err := filter.Add([]byte("one more")); !errors.Is(err, ErrFilterFull) {
+		t.Errorf("add to full filter: %v", err)
+	}
+
+	if lf := filter.LoadFactor(); lf != float64(filter.Len())/float64(filter.table.SizeInTags()+4) {
+		t.Errorf("load factor %v does not count the stash", lf)
+	}
+
+	for i := 0; i < inserted; i++ {
+		if !filter.Contain([]byte(strconv.Itoa(i))) {
+			t.Errorf("find %v fail", i)
+		}
+	}
+
+	// each delete frees a slot for a stashed fingerprint
+	for i := 0; i < inserted; i++ {
+		if !filter.Delete([]byte(strconv.Itoa(i))) {
+			t.Errorf("delete %v fail", i)
+		}
+
+		for j := i + 1; j < inserted; j++ {
+			if !filter.Contain([]byte(strconv.Itoa(j))) {
+				t.Fatalf("find %v fail after deleting %v", j, i)
+			}
+		}
+	}
+
+	if filter.Len() != 0 || len(filter.stash) != 0 {
+		t.Errorf("len %v stash %v after deleting all", filter.Len(), len(filter.stash))
+	}
+}
+
+func TestCuckoo_ExactCapacity(t *testing.T) {
+	for _, numBucket := range []uint32{1, 3, 100, 1000003} {
+		x := indexer{numBucket: numBucket, bitsPerItem: 16}
+		for i := uint32(0); i < numBucket && i < 1000; i++ {
+			for tag := uint64(1); tag < 1<<16; tag += 997 {
+				i2 := x.altIndex(i, tag)
+				if i2 >= numBucket || x.altIndex(i2, tag) != i {
+					t.Fatalf("%v buckets: alt of %v is %v, back %v", numBucket, i, i2, x.altIndex(i2, tag))
+				}
+			}
+		}
+	}
+
+	const numKeys = 600000
+	filter := NewCuckooFilter(WithSeed(1), WithNumKeys(numKeys), WithExactCapacity())
+	if size := filter.table.SizeInTags(); size > numKeys*105/100 {
+		t.Errorf("%v slots for %v keys", size, numKeys)
+	}
+
+	for i := 0; i < numKeys*95/100; i++ {
+		if err := filter.Add([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatalf("add %v: %v", i, err)
+		}
+	}
+
+	for i := 0; i < numKeys*95/100; i++ {
+		if !filter.Contain([]byte(strconv.Itoa(i))) {
+			t.Fatalf("find %v fail", i)
+		}
+	}
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded Cuckoo
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if !loaded.Contain([]byte("1")) {
+		t.Error("find 1 in the loaded filter fail")
+	}
+}
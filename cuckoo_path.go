@@ -0,0 +1,132 @@
+package cuckoo
+
+const (
+	// longest chain of moves pathInsert tries
+	pathMaxDepth = 5
+	// buckets pathInsert visits at most
+	pathMaxNodes = 512
+)
+
+// pathNode is a bucket reached by moving tag out of its parent bucket
+type pathNode struct {
+	bucket uint32
+	tag    uint64
+	parent int // -1 for the buckets of the new item
+	depth  int
+}
+
+// TryAdd inserts x like Add, but it first searches for a short chain of
+// moves ending in a free slot and changes nothing when there is none.
+// It returns ErrNoPath in that case, ErrFilterFull and ErrTooManyDuplicates
+// as Add does.
+func (c *Cuckoo) TryAdd(x []byte) error {
+	i, tag := c.generateIndexTagHash(x)
+	return c.tryAddTag(i, tag)
+}
+
+func (c *Cuckoo) tryAddTag(i uint32, tag uint64) error {
+	if c.IsFull() {
+		return ErrFilterFull
+	}
+
+	if c.duplicates(i, tag) {
+		return ErrTooManyDuplicates
+	}
+
+	if !c.pathInsert(i, tag) {
+		return ErrNoPath
+	}
+
+	return nil
+}
+
+// pathInsert searches breadth first from bucket[i1] and bucket[i2]: a node
+// is a bucket, its children are the alternate buckets of the tags it holds.
+// The first node with an empty entry ends the path, the tags are moved from
+// the end of the path back to the start and f takes the entry freed in
+// bucket[i1] or bucket[i2].
+func (c *Cuckoo) pathInsert(i1 uint32, tag uint64) bool {
+	i2 := c.altIndex(i1, tag)
+	if _, ok := c.table.Insert(i1, tag, false); ok {
+		c.count++
+		return true
+	}
+	if _, ok := c.table.Insert(i2, tag, false); ok {
+		c.count++
+		return true
+	}
+
+	nodes := append(c.path[:0], pathNode{bucket: i1, parent: -1})
+	if i2 != i1 {
+		nodes = append(nodes, pathNode{bucket: i2, parent: -1})
+	}
+	defer func() { c.path = nodes }()
+
+	for k := 0; k < len(nodes); k++ {
+		n := nodes[k]
+		tags := c.table.ReadBucket(n.bucket, c.tags[:0])
+		c.tags = tags
+		for _, t := range tags {
+			if t == 0 {
+				c.applyPath(nodes, k, tag)
+				return true
+			}
+		}
+
+		if n.depth == pathMaxDepth {
+			continue
+		}
+
+		for _, t := range tags {
+			alt := c.altIndex(n.bucket, t)
+			if len(nodes) == pathMaxNodes || onPath(nodes, k, alt) {
+				continue
+			}
+
+			nodes = append(nodes, pathNode{bucket: alt, tag: t, parent: k, depth: n.depth + 1})
+		}
+	}
+
+	return false
+}
+
+// place inserts tag by the InsertStrategy of the filter, it returns false
+// when it stashed a tag like insert.
+func (c *Cuckoo) place(i uint32, tag uint64) bool {
+	if c.opt.strategy == BreadthFirst && c.pathInsert(i, tag) {
+		return true
+	}
+
+	return c.insert(i, tag)
+}
+
+// applyPath moves the tags on the path ending at nodes[k], every move goes
+// into the slot the previous one freed, then inserts tag at the start.
+func (c *Cuckoo) applyPath(nodes []pathNode, k int, tag uint64) {
+	for nodes[k].parent >= 0 {
+		n := nodes[k]
+		from := nodes[n.parent].bucket
+		c.table.Delete(from, n.tag)
+		if _, ok := c.table.Insert(n.bucket, n.tag, false); !ok {
+			panic("cuckoo: path move into a full bucket")
+		}
+		k = n.parent
+	}
+
+	if _, ok := c.table.Insert(nodes[k].bucket, tag, false); !ok {
+		panic("cuckoo: path start is full")
+	}
+	c.count++
+}
+
+// onPath reports whether bucket is on the path ending at nodes[k], a path
+// through a bucket twice could move a tag into a slot that is not free.
+func onPath(nodes []pathNode, k int, bucket uint32) bool {
+	for ; k >= 0; k = nodes[k].parent {
+		if nodes[k].bucket == bucket {
+			return true
+		}
+	}
+
+	return false
+}
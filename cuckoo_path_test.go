@@ -0,0 +1,134 @@
+package cuckoo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCuckoo_TryAdd(t *testing.T) {
+	for _, table := range []Table{&singleTable{}, NewPackedTable()} {
+		filter := NewCuckooFilter(WithSeed(1), WithNumKeys(4000), WithBitsPerItem(13), WithTable(table))
+
+		var inserted int
+		for ; ; inserted++ {
+			before, _ := filter.MarshalBinary()
+			err := filter.TryAdd([]byte(strconv.Itoa(inserted)))
+			if err == nil {
+				continue
+			}
+
+			if !errors.Is(err, ErrNoPath) {
+				t.Fatalf("%v: add %v: %v", table, inserted, err)
+			}
+
+			after, _ := filter.MarshalBinary()
+			if string(before) != string(after) {
+				t.Errorf("%v: failed add changed the filter", table)
+			}
+			break
+		}
+
+		if len(filter.stash) != 0 {
+			t.Errorf("%v: stash used", table)
+		}
+
+		if lf := filter.LoadFactor(); lf < 0.9 {
+			t.Errorf("%v: load factor %v", table, lf)
+		}
+
+		if filter.Len() != uint32(inserted) {
+			t.Errorf("%v: len %v, want %v", table, filter.Len(), inserted)
+		}
+
+		for i := 0; i < inserted; i++ {
+			if !filter.Contain([]byte(strconv.Itoa(i))) {
+				t.Errorf("%v: find %v fail", table, i)
+			}
+		}
+	}
+}
+
+func TestCuckoo_BreadthFirst(t *testing.T) {
+	filter := NewCuckooFilter(WithSeed(1), WithNumKeys(4000), WithInsertStrategy(BreadthFirst))
+
+	var inserted int
+	for ; !filter.IsFull(); inserted++ {
+		if err := filter.Add([]byte(strconv.Itoa(inserted))); err != nil {
+			t.Fatalf("add %v: %v", inserted, err)
+		}
+	}
+
+	if lf := filter.LoadFactor(); lf < 0.9 {
+		t.Errorf("load factor %v", lf)
+	}
+
+	for i := 0; i < inserted; i++ {
+		if !filter.Contain([]byte(strconv.Itoa(i))) {
+			t.Errorf("find %v fail", i)
+		}
+	}
+
+	for i := 0; i < inserted; i++ {
+		if !filter.Delete([]byte(strconv.Itoa(i))) {
+			t.Errorf("delete %v fail", i)
+		}
+	}
+
+	if filter.Len() != 0 || filter.IsFull() {
+		t.Errorf("len %v full %v after deleting all", filter.Len(), filter.IsFull())
+	}
+}
+
+// BenchmarkCuckoo_InsertStrategy adds and deletes an item in a filter held
+// at a load factor, it reports the mean and tail latency of the adds.
+func BenchmarkCuckoo_InsertStrategy(b *testing.B) {
+	strategies := []struct {
+		name     string
+		strategy InsertStrategy
+	}{
+		{"RandomWalk", RandomWalk},
+		{"BreadthFirst", BreadthFirst},
+	}
+	for _, s := range strategies {
+		for _, load := range []float64{0.90, 0.93, 0.95} {
+			b.Run(fmt.Sprintf("%v/load=%v", s.name, load), func(b *testing.B) {
+				filter := NewCuckooFilter(WithSeed(1), WithNumKeys(1<<17), WithInsertStrategy(s.strategy))
+				var n int
+				for ; filter.LoadFactor() < load; n++ {
+					filter.Add([]byte(strconv.Itoa(n)))
+				}
+
+				keys := make([][]byte, 1<<12)
+				for k := range keys {
+					keys[k] = []byte(strconv.Itoa(n + k))
+				}
+				lat := make([]time.Duration, b.N)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					key := keys[i&(len(keys)-1)]
+					start := time.Now()
+					err := filter.Add(key)
+					lat[i] = time.Since(start)
+					if err == nil {
+						filter.Delete(key)
+					}
+				}
+				b.StopTimer()
+
+				sort.Slice(lat, func(x, y int) bool { return lat[x] < lat[y] })
+				var sum time.Duration
+				for _, d := range lat {
+					sum += d
+				}
+				b.ReportMetric(float64(sum.Nanoseconds())/float64(b.N), "add-ns/op")
+				b.ReportMetric(float64(lat[b.N*99/100].Nanoseconds()), "p99-ns")
+				b.ReportMetric(float64(lat[b.N*999/1000].Nanoseconds()), "p999-ns")
+			})
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/maphash"
+)
+
+// The Fields methods index an item made of several fields, every field is
+// hashed after its length as 8 little-endian bytes, so ("ab", "c") and
+// ("a", "bc") are different items. The fields are streamed through the hash
+// function, the item is the same as the []byte of the framed fields.
+
+func (c *Cuckoo) InsertFields(fields ...[]byte) bool {
+	i, tag := c.indexTag(c.hashFields(fields))
+	return c.addTag(i, tag) == nil
+}
+
+func (c *Cuckoo) ContainFields(fields ...[]byte) bool {
+	i, tag := c.indexTag(c.hashFields(fields))
+	return c.containTag(i, tag)
+}
+
+func (c *Cuckoo) DeleteFields(fields ...[]byte) bool {
+	i, tag := c.indexTag(c.hashFields(fields))
+	return c.deleteTag(i, tag)
+}
+
+// hashFields returns the hashes hashItem returns for the framed fields
+func (c *Cuckoo) hashFields(fields [][]byte) (uint64, uint64) {
+	wide := c.wide()
+	switch h := c.opt.hf.(type) {
+	case *seededHash:
+		hv := fmix64(fnv1aFields(fnvOffset64^fmix64(h.seed), fields))
+		if !wide {
+			return hv, 0
+		}
+		return hv, fmix64(fnv1aFields(fnvOffset64^fmix64(h.seed^seed2Mix), fields))
+	case *sipHash:
+		hv := sipFields(h.k0, h.k1, fields)
+		if !wide {
+			return hv, 0
+		}
+		return hv, sipFields(h.k0^seed2Mix, h.k1, fields)
+	case *processHash:
+		hv := maphashFields(h.Seed(), fields)
+		if !wide {
+			return hv, 0
+		}
+		return hv, maphashFields(h.seed2, fields)
+	}
+
+	h := c.opt.hf
+	h.Reset()
+	c.writeFields(h, fields)
+	hv := h.Sum64()
+	if !wide {
+		return hv, 0
+	}
+
+	h.Write(hash128Marker[:])
+	return hv, h.Sum64()
+}
+
+func fnv1aFields(h uint64, fields [][]byte) uint64 {
+	var n [8]byte
+	for _, f := range fields {
+		binary.LittleEndian.PutUint64(n[:], uint64(len(f)))
+		h = fnv1a(fnv1a(h, n[:]), f)
+	}
+
+	return h
+}
+
+func sipFields(k0, k1 uint64, fields [][]byte) uint64 {
+	s := newSipState(k0, k1)
+	var n [8]byte
+	for _, f := range fields {
+		binary.LittleEndian.PutUint64(n[:], uint64(len(f)))
+		s.write(n[:])
+		s.write(f)
+	}
+
+	return s.sum()
+}
+
+func maphashFields(seed maphash.Seed, fields [][]byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	var n [8]byte
+	for _, f := range fields {
+		binary.LittleEndian.PutUint64(n[:], uint64(len(f)))
+		h.Write(n[:])
+		h.Write(f)
+	}
+
+	return h.Sum64()
+}
+
+// writeFields writes the framed fields to a hash.Hash64 given by WithHash,
+// the lengths go through the key buffer of the filter so they do not
+// escape to the heap.
+func (c *Cuckoo) writeFields(h hash.Hash64, fields [][]byte) {
+	for _, f := range fields {
+		binary.LittleEndian.PutUint64(c.key[:], uint64(len(f)))
+		h.Write(c.key[:])
+		h.Write(f)
+	}
+}
@@ -0,0 +1,72 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strconv"
+	"testing"
+)
+
+func TestCuckoo_Fields(t *testing.T) {
+	filters := map[string]*Cuckoo{
+		"default": NewCuckooFilter(),
+		"seeded":  NewCuckooFilter(WithSeed(1)),
+		"custom":  NewCuckooFilter(WithHash(fnv.New64a())),
+		"wide":    NewCuckooFilter(WithSeed(1), WithBitsPerItem(48)),
+	}
+
+	for name, filter := range filters {
+		for i := 0; i < 1000; i++ {
+			s := strconv.Itoa(i)
+			if !filter.InsertFields([]byte("tenant"), []byte(s), []byte(s+"x")) {
+				t.Fatalf("%v: insert %v fail", name, i)
+			}
+		}
+
+		for i := 0; i < 1000; i++ {
+			s := strconv.Itoa(i)
+			if !filter.ContainFields([]byte("tenant"), []byte(s), []byte(s+"x")) {
+				t.Errorf("%v: find %v fail", name, i)
+			}
+
+			// the same item as its framed bytes
+			var framed []byte
+			for _, f := range []string{"tenant", s, s + "x"} {
+				framed = binary.LittleEndian.AppendUint64(framed, uint64(len(f)))
+				framed = append(framed, f...)
+			}
+			if !filter.Contain(framed) {
+				t.Errorf("%v: find framed %v fail", name, i)
+			}
+		}
+
+		// moving a byte between fields makes another item
+		var found int
+		for i := 0; i < 1000; i++ {
+			s := strconv.Itoa(i)
+			if filter.ContainFields([]byte("tenant"), []byte(s+"x"), []byte(s)) || filter.ContainFields([]byte("tenan"), []byte("t"+s), []byte(s+"x")) {
+				found++
+			}
+		}
+		if found > 10 {
+			t.Errorf("%v: %v of 1000 regrouped fields found", name, found)
+		}
+
+		for i := 0; i < 1000; i++ {
+			s := strconv.Itoa(i)
+			if !filter.DeleteFields([]byte("tenant"), []byte(s), []byte(s+"x")) {
+				t.Errorf("%v: delete %v fail", name, i)
+			}
+		}
+
+		tenant, user := []byte("tenant"), []byte("user")
+		allocs := testing.AllocsPerRun(1000, func() {
+			filter.InsertFields(tenant, user)
+			filter.ContainFields(tenant, user)
+			filter.DeleteFields(tenant, user)
+		})
+		if allocs != 0 {
+			t.Errorf("%v: %v allocs per run", name, allocs)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// Filter is a Cuckoo filter of keys of type K, each key is encoded to bytes
+// or hashed by a function given at creation.
+type Filter[K any] struct {
+	c    *Cuckoo
+	enc  func(dst []byte, k K) []byte
+	hash func(k K) uint64
+	buf  []byte
+}
+
+// NewFilter creates a filter that indexes the bytes enc appends to dst for a
+// key, equal keys must encode to equal bytes. StringKey, IntegerKey and
+// ArrayKey are encoders for the common key types. It takes the same options
+// as NewCuckooFilter.
+func NewFilter[K any](enc func(dst []byte, k K) []byte, opts ...Option) *Filter[K] {
+	return &Filter[K]{
+		c:   NewCuckooFilter(opts...),
+		enc: enc,
+	}
+}
+
+// NewHashFilter creates a filter that indexes the 64-bit hash of a key,
+// like Cuckoo.InsertHash the hash must be well mixed.
+func NewHashFilter[K any](hash func(k K) uint64, opts ...Option) *Filter[K] {
+	return &Filter[K]{
+		c:    NewCuckooFilter(opts...),
+		hash: hash,
+	}
+}
+
+// Add inserts k, errors are the ones of Cuckoo.Add
+func (f *Filter[K]) Add(k K) error {
+	i, tag := f.indexTag(k)
+	return f.c.addTag(i, tag)
+}
+
+func (f *Filter[K]) Has(k K) bool {
+	i, tag := f.indexTag(k)
+	return f.c.containTag(i, tag)
+}
+
+func (f *Filter[K]) Remove(k K) bool {
+	i, tag := f.indexTag(k)
+	return f.c.deleteTag(i, tag)
+}
+
+func (f *Filter[K]) Len() uint32 {
+	return f.c.Len()
+}
+
+func (f *Filter[K]) LoadFactor() float64 {
+	return f.c.LoadFactor()
+}
+
+func (f *Filter[K]) BitsPerItem() float64 {
+	return f.c.BitsPerItem()
+}
+
+func (f *Filter[K]) MarshalBinary() ([]byte, error) {
+	return f.c.MarshalBinary()
+}
+
+func (f *Filter[K]) UnmarshalBinary(data []byte) error {
+	return f.c.UnmarshalBinary(data)
+}
+
+func (f *Filter[K]) indexTag(k K) (uint32, uint64) {
+	if f.hash != nil {
+		return f.c.indexTag(f.c.splitHash(f.hash(k)))
+	}
+
+	f.buf = f.enc(f.buf[:0], k)
+	return f.c.generateIndexTagHash(f.buf)
+}
+
+// Integer is the set of integer types IntegerKey encodes
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// StringKey encodes a string as its bytes
+func StringKey[K ~string](dst []byte, k K) []byte {
+	return append(dst, k...)
+}
+
+// IntegerKey encodes an integer as 8 little-endian bytes, the encoding of
+// Cuckoo.InsertUint64.
+func IntegerKey[K Integer](dst []byte, k K) []byte {
+	return binary.LittleEndian.AppendUint64(dst, uint64(k))
+}
+
+// ByteArray is the set of byte array types ArrayKey encodes, the sizes of
+// common ids and digests.
+type ByteArray interface {
+	~[4]byte | ~[8]byte | ~[12]byte | ~[16]byte | ~[20]byte |
+		~[24]byte | ~[28]byte | ~[32]byte | ~[48]byte | ~[64]byte
+}
+
+// ArrayKey encodes a byte array such as a [16]byte UUID or a [32]byte
+// digest as its bytes.
+func ArrayKey[K ByteArray](dst []byte, k K) []byte {
+	return append(dst, unsafe.Slice((*byte)(unsafe.Pointer(&k)), unsafe.Sizeof(k))...)
+}
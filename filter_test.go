@@ -0,0 +1,59 @@
+package cuckoo
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	type userID uint32
+	ids := NewFilter(IntegerKey[userID], WithSeed(1), WithNumKeys(10000))
+	names := NewFilter(StringKey[string], WithSeed(1), WithNumKeys(10000), WithBitsPerItem(13), WithTable(NewPackedTable()))
+	digests := NewFilter(ArrayKey[[32]byte], WithSeed(1), WithNumKeys(10000))
+	hashed := NewHashFilter(func(k int) uint64 { return fmix64(uint64(k)) }, WithSeed(1), WithNumKeys(10000))
+
+	for i := 0; i < 1000; i++ {
+		s := strconv.Itoa(i)
+		if ids.Add(userID(i)) != nil || names.Add(s) != nil || digests.Add(sha256.Sum256([]byte(s))) != nil || hashed.Add(i) != nil {
+			t.Fatalf("add %v fail", i)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		s := strconv.Itoa(i)
+		if !ids.Has(userID(i)) || !names.Has(s) || !digests.Has(sha256.Sum256([]byte(s))) || !hashed.Has(i) {
+			t.Errorf("find %v fail", i)
+		}
+	}
+
+	// the encoders index like the []byte and Uint64 methods
+	digest := sha256.Sum256([]byte("7"))
+	if !ids.c.ContainUint64(7) || !names.c.ContainString("7") || !digests.c.Contain(digest[:]) {
+		t.Error("encodings differ from the Cuckoo methods")
+	}
+
+	for i := 0; i < 1000; i++ {
+		s := strconv.Itoa(i)
+		if !ids.Remove(userID(i)) || !names.Remove(s) || !digests.Remove(sha256.Sum256([]byte(s))) || !hashed.Remove(i) {
+			t.Errorf("remove %v fail", i)
+		}
+	}
+
+	if ids.Len()+names.Len()+digests.Len()+hashed.Len() != 0 {
+		t.Error("items left after removing all")
+	}
+}
+
+func TestFilter_Allocs(t *testing.T) {
+	filter := NewFilter(StringKey[string], WithSeed(1))
+	filter.Add("warm up the buffer")
+	allocs := testing.AllocsPerRun(1000, func() {
+		filter.Add("a key")
+		filter.Has("a key")
+		filter.Remove("a key")
+	})
+	if allocs != 0 {
+		t.Errorf("%v allocs per run", allocs)
+	}
+}
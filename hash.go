@@ -0,0 +1,129 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/maphash"
+)
+
+// hash function identities, recorded by MarshalBinary
+const (
+	hashUnset   uint8 = iota
+	hashProcess       // default maphash, random seed per process
+	hashSeeded        // seededHash, see WithSeed
+	hashCustom        // user supplied by WithHash
+	hashKeyed         // sipHash, see WithKey, the seed is its key check value
+)
+
+const (
+	fnvOffset64 = 14695981039346656037
+	fnvPrime64  = 1099511628211
+
+	// seeds the second hash of seededHash.sum128
+	seed2Mix = 0x9e3779b97f4a7c15
+)
+
+// hashCheckMessage is hashed by a hash given by WithHash or WithKey,
+// MarshalBinary records the result to tell the hashes apart.
+var hashCheckMessage = []byte("cuckoo hash check")
+
+var (
+	_ hash.Hash64 = &seededHash{}
+	_ hash.Hash64 = &processHash{}
+	_ sum64er     = &seededHash{}
+	_ sum64er     = &processHash{}
+)
+
+// sum64er is implemented by the built-in hash functions, sum64 and sum128
+// digest a whole item without touching the hash state, so they are safe for
+// concurrent use and hash64 and hash128 prefer them.
+type sum64er interface {
+	sum64(b []byte) uint64
+	// sum128 returns sum64 and a second, independent hash
+	sum128(b []byte) (uint64, uint64)
+}
+
+// processHash is the default maphash with a random seed per process.
+type processHash struct {
+	maphash.Hash
+	seed2 maphash.Seed
+}
+
+func newProcessHash() *processHash {
+	h := &processHash{seed2: maphash.MakeSeed()}
+	h.SetSeed(maphash.MakeSeed())
+	return h
+}
+
+func (h *processHash) sum64(b []byte) uint64 {
+	return maphash.Bytes(h.Seed(), b)
+}
+
+func (h *processHash) sum128(b []byte) (uint64, uint64) {
+	return maphash.Bytes(h.Seed(), b), maphash.Bytes(h.seed2, b)
+}
+
+// seededHash is 64-bit FNV-1a started from a seeded offset and finished with
+// the MurmurHash3 fmix64 avalanche, so the high bits used for the bucket
+// index are well mixed. The result only depends on the seed and the input,
+// it is the same on every process and machine.
+type seededHash struct {
+	seed uint64
+	h    uint64
+}
+
+func newSeededHash(seed uint64) *seededHash {
+	s := &seededHash{seed: seed}
+	s.Reset()
+	return s
+}
+
+func (s *seededHash) Write(p []byte) (int, error) {
+	s.h = fnv1a(s.h, p)
+	return len(p), nil
+}
+
+func (s *seededHash) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, s.Sum64())
+}
+
+func (s *seededHash) Reset() {
+	s.h = fnvOffset64 ^ fmix64(s.seed)
+}
+
+func (s *seededHash) Size() int {
+	return 8
+}
+
+func (s *seededHash) BlockSize() int {
+	return 1
+}
+
+func (s *seededHash) Sum64() uint64 {
+	return fmix64(s.h)
+}
+
+func (s *seededHash) sum64(b []byte) uint64 {
+	return fmix64(fnv1a(fnvOffset64^fmix64(s.seed), b))
+}
+
+func (s *seededHash) sum128(b []byte) (uint64, uint64) {
+	return s.sum64(b), fmix64(fnv1a(fnvOffset64^fmix64(s.seed^seed2Mix), b))
+}
+
+func fnv1a(h uint64, p []byte) uint64 {
+	for _, b := range p {
+		h ^= uint64(b)
+		h *= fnvPrime64
+	}
+	return h
+}
+
+func fmix64(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
@@ -0,0 +1,49 @@
+package cuckoo
+
+import (
+	"testing"
+)
+
+func TestSeededHash(t *testing.T) {
+	// golden values, they must never change or persisted filters break
+	ts := []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xd430cd2ca02da65e},
+		{"a", 0x2ddfc22dc486fd44},
+		{"cuckoo", 0x3e96ff0391ab89ec},
+	}
+
+	for _, te := range ts {
+		h := newSeededHash(42)
+		if got := hash64([]byte(te.in), h); got != te.want {
+			t.Errorf("hash %q = %#x, want %#x", te.in, got, te.want)
+		}
+
+		h.Reset()
+		h.Write([]byte(te.in))
+		if got := h.Sum64(); got != te.want {
+			t.Errorf("streaming hash %q = %#x, want %#x", te.in, got, te.want)
+		}
+	}
+
+	if hv, hv2 := newSeededHash(42).sum128([]byte("cuckoo")); hv != 0x3e96ff0391ab89ec || hv2 != 0x9287f27fdd7ea062 {
+		t.Errorf("sum128 = %#x %#x", hv, hv2)
+	}
+
+	ph := newProcessHash()
+	ph.Write([]byte("cuckoo"))
+	if ph.Sum64() != hash64([]byte("cuckoo"), ph) {
+		t.Errorf("default hash sum64 differs from streaming")
+	}
+
+	if hash64([]byte("a"), newSeededHash(1)) == hash64([]byte("a"), newSeededHash(2)) {
+		t.Errorf("seed does not change the hash")
+	}
+
+	filter := NewCuckooFilter(WithSeed(7))
+	if i, tag := filter.generateIndexTagHash([]byte("cuckoo")); i != 1260 || tag != 8129 {
+		t.Errorf("index %v tag %v, want 1260 8129", i, tag)
+	}
+}
@@ -0,0 +1,76 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// The String and Uint64 methods index a key like the []byte methods index
+// its bytes, a uint64 key as its 8 little-endian bytes, so either form finds
+// an item added by the other. None of them allocates.
+
+func (c *Cuckoo) InsertString(s string) bool {
+	return c.Insert(stringBytes(s))
+}
+
+func (c *Cuckoo) ContainString(s string) bool {
+	return c.Contain(stringBytes(s))
+}
+
+func (c *Cuckoo) DeleteString(s string) bool {
+	return c.Delete(stringBytes(s))
+}
+
+func (c *Cuckoo) InsertUint64(k uint64) bool {
+	return c.Insert(c.uint64Bytes(k))
+}
+
+func (c *Cuckoo) ContainUint64(k uint64) bool {
+	return c.Contain(c.uint64Bytes(k))
+}
+
+func (c *Cuckoo) DeleteUint64(k uint64) bool {
+	return c.Delete(c.uint64Bytes(k))
+}
+
+// InsertHash inserts an item by a 64-bit hash the caller computed, the hash
+// must be well mixed, its high 32 bits pick the bucket and the low bits the
+// fingerprint. Items inserted by hash are only found by ContainHash and
+// DeleteHash.
+func (c *Cuckoo) InsertHash(hv uint64) bool {
+	i, tag := c.indexTag(c.splitHash(hv))
+	return c.addTag(i, tag) == nil
+}
+
+func (c *Cuckoo) ContainHash(hv uint64) bool {
+	i, tag := c.indexTag(c.splitHash(hv))
+	return c.containTag(i, tag)
+}
+
+func (c *Cuckoo) DeleteHash(hv uint64) bool {
+	i, tag := c.indexTag(c.splitHash(hv))
+	return c.deleteTag(i, tag)
+}
+
+// splitHash returns hv as the hashes of hashItem, fingerprints wider than
+// 32 bits take their bits from hv remixed.
+func (c *Cuckoo) splitHash(hv uint64) (uint64, uint64) {
+	if c.wide() {
+		return hv, fmix64(hv ^ seed2Mix)
+	}
+
+	return hv, 0
+}
+
+// uint64Bytes encodes k into the key buffer of the filter, a local array
+// would escape to the heap through the hash.Hash64 interface.
+func (c *Cuckoo) uint64Bytes(k uint64) []byte {
+	binary.LittleEndian.PutUint64(c.key[:], k)
+	return c.key[:]
+}
+
+// stringBytes returns the bytes of s without a copy, the hash functions
+// only read them.
+func stringBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
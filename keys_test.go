@@ -0,0 +1,66 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"strconv"
+	"testing"
+)
+
+func TestCuckoo_Keys(t *testing.T) {
+	filter := NewCuckooFilter(WithSeed(1), WithNumKeys(10000))
+	for i := 0; i < 1000; i++ {
+		if !filter.InsertString(strconv.Itoa(i)) || !filter.InsertUint64(uint64(i)) || !filter.InsertHash(fmix64(uint64(i))) {
+			t.Fatalf("insert %v fail", i)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(i))
+		if !filter.Contain([]byte(strconv.Itoa(i))) || !filter.ContainString(strconv.Itoa(i)) ||
+			!filter.Contain(b[:]) || !filter.ContainUint64(uint64(i)) || !filter.ContainHash(fmix64(uint64(i))) {
+			t.Errorf("find %v fail", i)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !filter.DeleteString(strconv.Itoa(i)) || !filter.DeleteUint64(uint64(i)) || !filter.DeleteHash(fmix64(uint64(i))) {
+			t.Errorf("delete %v fail", i)
+		}
+	}
+
+	if filter.Len() != 0 {
+		t.Errorf("len %v after deleting all", filter.Len())
+	}
+}
+
+func TestCuckoo_KeysAllocs(t *testing.T) {
+	filters := map[string]*Cuckoo{
+		"default": NewCuckooFilter(),
+		"seeded":  NewCuckooFilter(WithSeed(1)),
+		"custom":  NewCuckooFilter(WithHash(fnv.New64a())),
+		"wide":    NewCuckooFilter(WithSeed(1), WithBitsPerItem(48)),
+	}
+
+	for name, filter := range filters {
+		s := "a string key"
+		var k, hv uint64
+		allocs := testing.AllocsPerRun(1000, func() {
+			k++
+			hv += 0x9e3779b97f4a7c15
+			filter.InsertString(s)
+			filter.ContainString(s)
+			filter.DeleteString(s)
+			filter.InsertUint64(k)
+			filter.ContainUint64(k)
+			filter.DeleteUint64(k)
+			filter.InsertHash(hv)
+			filter.ContainHash(hv)
+			filter.DeleteHash(hv)
+		})
+		if allocs != 0 {
+			t.Errorf("%v: %v allocs per run", name, allocs)
+		}
+	}
+}
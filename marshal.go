@@ -0,0 +1,272 @@
+package cuckoo
+
+import (
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	marshalMagic   = "CKOO"
+	marshalVersion = 1
+)
+
+var (
+	_ encoding.BinaryMarshaler   = &Cuckoo{}
+	_ encoding.BinaryUnmarshaler = &Cuckoo{}
+
+	// ErrCorrupt is returned when the data passed to UnmarshalBinary is not
+	// a marshaled filter.
+	ErrCorrupt = errors.New("cuckoo: corrupt filter data")
+
+	// ErrHashMismatch is returned when the hash function of the receiver
+	// can not index the marshaled filter the same way.
+	ErrHashMismatch = errors.New("cuckoo: hash function mismatch")
+)
+
+/*
+	magic "CKOO" | version u8 | table name len u8 | table name
+	hash kind u8 | hash seed u64
+	kicks u32 | numKeys u32 | tagsPerBucket u32 | bitsPerItem u32
+	numBucket u32 | count u32
+	stash size u32 | stash len u32 | stash len * (index u32 | tag u64)
+	table len u32 | table bytes
+
+	the hash seed of a custom or keyed hash is a check value, its hash of
+	hashCheckMessage.
+
+	all integers are little-endian.
+*/
+
+// MarshalBinary encodes the filter. Only the identity of the hash function
+// is recorded, a seed given by WithSeed is kept so the filter can be loaded
+// anywhere.
+func (c *Cuckoo) MarshalBinary() ([]byte, error) {
+	tb, err := c.table.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	name := c.table.String()
+	if len(name) > 0xff {
+		return nil, fmt.Errorf("cuckoo: table name %q too long", name)
+	}
+
+	data := make([]byte, 0, 56+12*len(c.stash)+len(name)+len(tb))
+	data = append(data, marshalMagic...)
+	data = append(data, marshalVersion, byte(len(name)))
+	data = append(data, name...)
+	data = append(data, c.opt.hashKind)
+	data = binary.LittleEndian.AppendUint64(data, c.opt.seed)
+	data = binary.LittleEndian.AppendUint32(data, uint32(c.opt.kicks))
+	data = binary.LittleEndian.AppendUint32(data, c.opt.numKeys)
+	data = binary.LittleEndian.AppendUint32(data, c.opt.tagsPerBucket)
+	data = binary.LittleEndian.AppendUint32(data, c.bitsPerItem)
+	data = binary.LittleEndian.AppendUint32(data, c.numBucket)
+	data = binary.LittleEndian.AppendUint32(data, c.count)
+	data = binary.LittleEndian.AppendUint32(data, c.opt.stashSize)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(c.stash)))
+	for _, v := range c.stash {
+		data = binary.LittleEndian.AppendUint32(data, v.index)
+		data = binary.LittleEndian.AppendUint64(data, v.tag)
+	}
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(tb)))
+	data = append(data, tb...)
+	return data, nil
+}
+
+// UnmarshalBinary restores a filter encoded by MarshalBinary.
+// A filter built WithSeed loads into any receiver that was not given another
+// hash function. A filter built WithHash must be loaded into a receiver
+// created with the same WithHash, e.g.
+//
+//	c := NewCuckooFilter(WithHash(h))
+//	err := c.UnmarshalBinary(data)
+//
+// A filter built with the default hash can not be loaded, its seed is private
+// to the process. Mismatches return ErrHashMismatch.
+// The table type is taken from the data.
+func (c *Cuckoo) UnmarshalBinary(data []byte) error {
+	r := reader{buf: data}
+	if string(r.bytes(len(marshalMagic))) != marshalMagic {
+		return ErrCorrupt
+	}
+
+	version := r.u8()
+	if r.err == nil && version != marshalVersion {
+		return fmt.Errorf("cuckoo: unsupported format version %v", version)
+	}
+
+	name := string(r.bytes(int(r.u8())))
+	hashKind, seed := r.u8(), r.u64()
+
+	opt := Options{
+		hf:             c.opt.hf,
+		hashKind:       c.opt.hashKind,
+		seed:           c.opt.seed,
+		rand:           c.opt.rand,
+		strategy:       c.opt.strategy,
+		floodThreshold: c.opt.floodThreshold,
+		flood:          c.opt.flood,
+		kicks:          int(r.u32()),
+		numKeys:        r.u32(),
+		tagsPerBucket:  r.u32(),
+		bitsPerItem:    r.u32(),
+	}
+	numBucket := r.u32()
+	count := r.u32()
+	stash, err := readStash(&r, &opt)
+	if err != nil {
+		return err
+	}
+	tb := r.bytes(int(r.u32()))
+	if r.err != nil || len(r.buf) != 0 {
+		return ErrCorrupt
+	}
+
+	if numBucket == 0 || opt.tagsPerBucket == 0 || opt.numKeys == 0 || opt.kicks <= 0 {
+		return ErrCorrupt
+	}
+
+	for _, v := range stash {
+		if v.index >= numBucket {
+			return ErrCorrupt
+		}
+	}
+
+	if err := opt.adoptHash(hashKind, seed); err != nil {
+		return err
+	}
+
+	if c.table != nil && c.table.String() == name {
+		opt.table = c.table
+	} else {
+		t, err := newTable(name)
+		if err != nil {
+			return err
+		}
+		opt.table = t
+	}
+	opt.apply()
+	if err := opt.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+
+	// the header is checked against the data before Init allocates
+	if n, ok := tableBytes(opt.table.String(), numBucket, opt.tagsPerBucket, opt.bitsPerItem); ok && n != uint64(len(tb)) {
+		return fmt.Errorf("%w: %v buckets take %v bytes, got %v", ErrCorrupt, numBucket, n, len(tb))
+	}
+
+	opt.table.Init(numBucket, opt.tagsPerBucket, opt.bitsPerItem)
+	opt.table.SetRand(opt.rand)
+	if err := opt.table.UnmarshalBinary(tb); err != nil {
+		return err
+	}
+
+	*c = Cuckoo{
+		indexer: indexer{
+			numBucket:   numBucket,
+			bitsPerItem: opt.bitsPerItem,
+		},
+		opt:   opt,
+		count: count,
+		table: opt.table,
+		stash: stash,
+	}
+	return nil
+}
+
+// readStash reads the stash and sets opt.stashSize
+func readStash(r *reader, opt *Options) ([]victim, error) {
+	opt.stashSize = r.u32()
+	n := r.u32()
+	if r.err != nil || n > opt.stashSize || uint64(n)*12 > uint64(len(r.buf)) {
+		return nil, ErrCorrupt
+	}
+
+	stash := make([]victim, n)
+	for k := range stash {
+		stash[k] = victim{index: r.u32(), tag: r.u64()}
+	}
+
+	return stash, nil
+}
+
+// adoptHash checks that the configured hash indexes like the recorded one,
+// an unconfigured or default hash is replaced by the recorded seeded hash.
+func (o *Options) adoptHash(kind uint8, seed uint64) error {
+	switch kind {
+	case hashSeeded:
+		if o.hashKind == hashUnset || o.hashKind == hashProcess {
+			WithSeed(seed)(o)
+			return nil
+		}
+
+		if o.hashKind == hashSeeded && o.seed == seed {
+			return nil
+		}
+
+		return fmt.Errorf("%w: filter uses seed %#x", ErrHashMismatch, seed)
+	case hashCustom:
+		if o.hashKind == hashCustom && o.seed == seed {
+			return nil
+		}
+
+		return fmt.Errorf("%w: filter uses a custom hash, load it WithHash with the same hash", ErrHashMismatch)
+	case hashKeyed:
+		if o.hashKind == hashKeyed && o.seed == seed {
+			return nil
+		}
+
+		return fmt.Errorf("%w: filter uses a secret key, load it WithKey with the same key", ErrHashMismatch)
+	case hashProcess:
+		return fmt.Errorf("%w: filter uses the per-process default hash, build it WithSeed to persist it", ErrHashMismatch)
+	}
+
+	return ErrCorrupt
+}
+
+// reader decodes little-endian fields, the first short read sticks in err.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.buf) {
+		r.err = ErrCorrupt
+		return nil
+	}
+
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) u8() uint8 {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+func (r *reader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) u64() uint64 {
+	b := r.bytes(8)
+	if b == nil {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint64(b)
+}
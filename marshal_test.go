@@ -0,0 +1,198 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc64"
+	"hash/fnv"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestCuckoo_MarshalBinary(t *testing.T) {
+	ts := []struct {
+		numKeys     uint32
+		bitsPerItem uint32
+		stashSize   uint32
+		table       func() Table
+	}{
+		{
+			numKeys: 1000,
+			table:   func() Table { return nil },
+		},
+		{
+			numKeys:     1000,
+			bitsPerItem: 12,
+			table:       func() Table { return nil },
+		},
+		{
+			numKeys:     1000,
+			bitsPerItem: 13,
+			table:       func() Table { return NewPackedTable() },
+		},
+		{
+			// overfill so that the stash is used
+			numKeys:     64,
+			bitsPerItem: 8,
+			table:       func() Table { return nil },
+		},
+		{
+			numKeys:     64,
+			bitsPerItem: 8,
+			stashSize:   4,
+			table:       func() Table { return nil },
+		},
+	}
+
+	for k, te := range ts {
+		filter := NewCuckooFilter(
+			WithHash(fnv.New64a()),
+			WithNumKeys(te.numKeys),
+			WithBitsPerItem(te.bitsPerItem),
+			WithTable(te.table()),
+			WithStashSize(te.stashSize),
+		)
+
+		for i := 0; i < int(te.numKeys)*2; i++ {
+			if !filter.Insert([]byte(strconv.Itoa(i))) {
+				break
+			}
+		}
+
+		data, err := filter.MarshalBinary()
+		if err != nil {
+			t.Fatalf("case %v marshal: %v", k, err)
+		}
+
+		loaded := NewCuckooFilter(WithHash(fnv.New64a()))
+		if err := loaded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("case %v unmarshal: %v", k, err)
+		}
+
+		if loaded.table.String() != filter.table.String() ||
+			loaded.count != filter.count ||
+			fmt.Sprint(loaded.stash) != fmt.Sprint(filter.stash) {
+			t.Errorf("case %v state mismatch", k)
+		}
+
+		for i := 0; i < int(te.numKeys)*4; i++ {
+			bs := []byte(strconv.Itoa(i))
+			if loaded.Contain(bs) != filter.Contain(bs) {
+				t.Errorf("case %v contain %v mismatch", k, i)
+			}
+		}
+
+		again, err := loaded.MarshalBinary()
+		if err != nil || string(again) != string(data) {
+			t.Errorf("case %v re-marshal mismatch", k)
+		}
+	}
+}
+
+func TestCuckoo_UnmarshalBinaryCorrupt(t *testing.T) {
+	filter := NewCuckooFilter(WithHash(fnv.New64a()), WithNumKeys(100))
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a header of 1<<24 buckets must fail before the table is allocated
+	huge := append([]byte{}, data...)
+	binary.LittleEndian.PutUint32(huge[len(marshalMagic)+2+len(filter.table.String())+1+8+16:], 1<<24)
+
+	for _, bad := range [][]byte{
+		nil,
+		data[:10],
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+		huge,
+	} {
+		c := NewCuckooFilter(WithHash(fnv.New64a()), WithNumKeys(1))
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		if err := c.UnmarshalBinary(bad); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("len %v expect ErrCorrupt, got %v", len(bad), err)
+		}
+		runtime.ReadMemStats(&after)
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+			t.Errorf("len %v allocated %v bytes", len(bad), alloc)
+		}
+	}
+
+	for _, table := range []Table{&singleTable{}, NewPackedTable()} {
+		table.Init(4, 4, 8)
+		if err := table.UnmarshalBinary(make([]byte, 3)); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%v: expect ErrCorrupt, got %v", table, err)
+		}
+	}
+}
+
+func TestCuckoo_UnmarshalBinaryHash(t *testing.T) {
+	seeded := NewCuckooFilter(WithSeed(42), WithNumKeys(100))
+	for i := 0; i < 50; i++ {
+		seeded.Insert([]byte(strconv.Itoa(i)))
+	}
+
+	data, err := seeded.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no hash or the default hash adopts the recorded seed
+	for _, c := range []*Cuckoo{{}, NewCuckooFilter(), NewCuckooFilter(WithSeed(42))} {
+		if err := c.UnmarshalBinary(data); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		for i := 0; i < 50; i++ {
+			if !c.Contain([]byte(strconv.Itoa(i))) {
+				t.Errorf("find %v fail", i)
+			}
+		}
+	}
+
+	for _, c := range []*Cuckoo{NewCuckooFilter(WithSeed(43)), NewCuckooFilter(WithHash(fnv.New64a()))} {
+		if err := c.UnmarshalBinary(data); !errors.Is(err, ErrHashMismatch) {
+			t.Errorf("expect ErrHashMismatch, got %v", err)
+		}
+	}
+
+	data, err = NewCuckooFilter(WithHash(fnv.New64a())).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewCuckooFilter(WithSeed(42)).UnmarshalBinary(data); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("custom hash: expect ErrHashMismatch, got %v", err)
+	}
+
+	for _, hf := range []hash.Hash64{fnv.New64(), crc64.New(crc64.MakeTable(crc64.ISO))} {
+		if err := NewCuckooFilter(WithHash(hf)).UnmarshalBinary(data); !errors.Is(err, ErrHashMismatch) {
+			t.Errorf("other custom hash: expect ErrHashMismatch, got %v", err)
+		}
+	}
+
+	if err := NewCuckooFilter(WithHash(fnv.New64a())).UnmarshalBinary(data); err != nil {
+		t.Errorf("same custom hash: %v", err)
+	}
+
+	// a zero check value is not taken for any hash
+	binary.LittleEndian.PutUint64(data[len(marshalMagic)+2+len(SingleTable)+1:], 0)
+	for _, hf := range []hash.Hash64{fnv.New64a(), fnv.New64()} {
+		if err := NewCuckooFilter(WithHash(hf)).UnmarshalBinary(data); !errors.Is(err, ErrHashMismatch) {
+			t.Errorf("zero check value: expect ErrHashMismatch, got %v", err)
+		}
+	}
+
+	data, err = NewCuckooFilter().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewCuckooFilter().UnmarshalBinary(data); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("default hash: expect ErrHashMismatch, got %v", err)
+	}
+}
@@ -7,46 +7,81 @@ import (
 
 // semi sort table
 // Using Permutation encoding to save 1 bit per tag
+//
+// Buckets hold 4 or 8 tags of 5 to 32 bits. The low 4 bits of the tags in a
+// bucket are sorted and stored as one codeword: 12 bits for 4 tags
+// (PermEncoding), 19 bits for 8 tags (rankEncode). 8 tag buckets save 13
+// bits per bucket.
+// Bucket i starts at bit i*kBitsPerBucket, the codeword is followed by the
+// high (direct) bits of every tag.
 type PackedTable struct {
 	kDirBitsPerTag  uint32
 	kBitsPerBucket  uint32
 	kBytesPerBucket uint32
 	kDirBitsMask    uint32
 
-	bitsPerItem uint32
-	len         uint32
-	numBuckets  uint32
-	buckets     []byte
-	perm        *PermEncoding
+	bitsPerItem   uint32
+	tagsPerBucket uint32
+	fast          bool // readTag and writeTag handle the width
+	len           uint32
+	numBuckets    uint32
+	buckets       []byte
+	perm          *PermEncoding
+	rand          *rand.Rand
 }
 
+// packedTags holds the decoded tags of a bucket, the packed tags are at most
+// 32 bits.
+type packedTags [8]uint32
+
 // NewPackedTable new a PackedTable
 func NewPackedTable() *PackedTable {
 	return &PackedTable{}
 }
 
-// Init init packed table
+// Init init packed table, it panics when tagsPerBucket is not 4 or 8.
 func (p *PackedTable) Init(numBucket, tagsPerBucket, bitsPerItem uint32) {
 	p.bitsPerItem = bitsPerItem
 	p.numBuckets = numBucket
+	p.tagsPerBucket = tagsPerBucket
+	p.fast = false
+	if tagsPerBucket == 4 {
+		switch bitsPerItem {
+		case 5, 6, 7, 8, 9, 13, 17:
+			p.fast = true
+		}
+	}
 
 	p.kDirBitsPerTag = bitsPerItem - 4
-	p.kBitsPerBucket = (3 + p.kDirBitsPerTag) * 4
-	p.kBytesPerBucket = (p.kBitsPerBucket + 7) >> 3
 	p.kDirBitsMask = ((1 << p.kDirBitsPerTag) - 1) << 4
+	switch tagsPerBucket {
+	case 4:
+		p.kBitsPerBucket = (3 + p.kDirBitsPerTag) * 4
+	case 8:
+		p.kBitsPerBucket = kRankCodewordBits + p.kDirBitsPerTag*8
+	default:
+		// 2 tags have C(17, 2) = 136 sorted low bits, a codeword would take
+		// the same 8 bits as the tags, so there is nothing to save.
+		panic(fmt.Sprintf("cuckoo: packed table supports 4 or 8 tags per bucket, got %v", tagsPerBucket))
+	}
+	p.kBytesPerBucket = (p.kBitsPerBucket + 7) >> 3
 
+	// 7 bytes of padding for the 8 bytes loads at the last bucket
 	p.len = p.kBytesPerBucket*numBucket + 7
+	if tagsPerBucket == 8 {
+		p.len = (p.kBitsPerBucket*numBucket+7)>>3 + 7
+	}
 	p.buckets = make([]byte, p.len)
-	p.perm = NewPermEncoding()
+	p.perm = sharedPermEncoding()
 }
 
-func (p *PackedTable) Insert(i uint32, tag uint32, kickout bool) (oldTag uint32, ok bool) {
-	tags := p.readTag(i)
+func (p *PackedTable) Insert(i uint32, tag uint64, kickout bool) (oldTag uint64, ok bool) {
+	tags := p.load(i)
 
-	for j := 0; j < 4; j++ {
+	for j := uint32(0); j < p.tagsPerBucket; j++ {
 		if tags[j] == 0 {
-			tags[j] = tag
-			p.writeTag(i, tags, true)
+			tags[j] = uint32(tag)
+			p.store(i, tags)
 			return 0, true
 		}
 	}
@@ -55,20 +90,42 @@ func (p *PackedTable) Insert(i uint32, tag uint32, kickout bool) (oldTag uint32,
 		return tag, false
 	}
 
-	r := rand.Intn(4)
-	oldTag = tags[r]
-	tags[r] = tag
-	p.writeTag(i, tags, true)
+	r := randIntn(p.rand, int(p.tagsPerBucket))
+	oldTag = uint64(tags[r])
+	tags[r] = uint32(tag)
+	p.store(i, tags)
 	return oldTag, false
 }
 
-func (p *PackedTable) Delete(i uint32, tag uint32) bool {
-	tags := p.readTag(i)
+func (p *PackedTable) SetRand(r *rand.Rand) {
+	p.rand = r
+}
 
-	for j := 0; j < 4; j++ {
-		if tags[j] == tag {
+func (p *PackedTable) Delete(i uint32, tag uint64) bool {
+	tags := p.load(i)
+
+	for j := uint32(0); j < p.tagsPerBucket; j++ {
+		if uint64(tags[j]) == tag {
 			tags[j] = 0
-			p.writeTag(i, tags, true)
+			p.store(i, tags)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *PackedTable) Find(i uint32, tag uint64) bool {
+	if p.fast {
+		tags := p.readTag(i)
+		t := uint32(tag)
+
+		return uint64(t) == tag && (tags[0] == t || tags[1] == t || tags[2] == t || tags[3] == t)
+	}
+
+	tags := p.load(i)
+	for j := uint32(0); j < p.tagsPerBucket; j++ {
+		if uint64(tags[j]) == tag {
 			return true
 		}
 	}
@@ -76,27 +133,143 @@ func (p *PackedTable) Delete(i uint32, tag uint32) bool {
 	return false
 }
 
-func (p *PackedTable) Find(i uint32, tag uint32) bool {
-	tags := p.readTag(i)
+func (p *PackedTable) ReadBucket(i uint32, tags []uint64) []uint64 {
+	t := p.load(i)
+	for _, v := range t[:p.tagsPerBucket] {
+		tags = append(tags, uint64(v))
+	}
 
-	return tags[0] == tag || tags[1] == tag || tags[2] == tag || tags[3] == tag
+	return tags
+}
+
+func (p *PackedTable) Limits() Limits {
+	bits := make([]uint32, 0, 28)
+	for b := uint32(5); b <= 32; b++ {
+		bits = append(bits, b)
+	}
+
+	return Limits{
+		BitsPerItem:   bits,
+		TagsPerBucket: []uint32{4, 8},
+	}
 }
 
 func (p *PackedTable) SizeInTags() uint32 {
-	return p.numBuckets * 4
+	return p.numBuckets * p.tagsPerBucket
 }
 
 func (p *PackedTable) Info() string {
 	return fmt.Sprintf("PackedHashtable with tag size: %v bits \n"+
-		"\t\t4 packed bits(3 bits after compression) and %v direct bits\n"+
-		"\t\tAssociativity: 4 \n"+
+		"\t\t4 packed bits(%.3g bits after compression) and %v direct bits\n"+
+		"\t\tAssociativity: %v \n"+
 		"\t\tTotal # of rows: %v\n"+
 		"\t\tTotal # slots: %v\n",
-		p.bitsPerItem, p.kDirBitsPerTag, p.numBuckets, p.SizeInTags())
+		p.bitsPerItem, float64(p.kBitsPerBucket)/float64(p.tagsPerBucket)-float64(p.kDirBitsPerTag),
+		p.kDirBitsPerTag, p.tagsPerBucket, p.numBuckets, p.SizeInTags())
 }
 
 func (p *PackedTable) String() string {
-	return "packed_table"
+	return PackedTableName
+}
+
+func (p *PackedTable) MarshalBinary() ([]byte, error) {
+	data := make([]byte, len(p.buckets))
+	copy(data, p.buckets)
+	return data, nil
+}
+
+func (p *PackedTable) UnmarshalBinary(data []byte) error {
+	if len(data) != len(p.buckets) {
+		return fmt.Errorf("%w: packed table expects %v bytes, got %v", ErrCorrupt, len(p.buckets), len(data))
+	}
+
+	copy(p.buckets, data)
+	return nil
+}
+
+// load decodes the tags of bucket i
+func (p *PackedTable) load(i uint32) packedTags {
+	var tags packedTags
+	if p.fast {
+		t := p.readTag(i)
+		copy(tags[:], t[:])
+		return tags
+	}
+
+	return p.readTags(i)
+}
+
+// store sorts and encodes the tags of bucket i
+func (p *PackedTable) store(i uint32, tags packedTags) {
+	if p.fast {
+		p.writeTag(i, [4]uint32{tags[0], tags[1], tags[2], tags[3]}, true)
+		return
+	}
+
+	p.writeTags(i, tags)
+}
+
+/* readTags and writeTags handle any width and bucket size, bucket i at bit
+ * offset i*kBitsPerBucket is
+ * codeword bits + dir bits of tag1 + dir bits of tag2 ...
+ * the same layout readTag and writeTag use for their widths.
+ */
+func (p *PackedTable) readTags(i uint32) packedTags {
+	var tags packedTags
+	var lowBits [8]uint8
+	b, off := p.bucket(i)
+	if p.tagsPerBucket == 4 {
+		low := p.perm.Decode(uint16(readBits(b, off, 12)))
+		copy(lowBits[:], low[:])
+		off += 12
+	} else {
+		lowBits = rankDecode(uint32(readBits(b, off, kRankCodewordBits)))
+		off += kRankCodewordBits
+	}
+
+	for j := uint32(0); j < p.tagsPerBucket; j++ {
+		tags[j] = uint32(readBits(b, off, p.kDirBitsPerTag))<<4 | uint32(lowBits[j])
+		off += p.kDirBitsPerTag
+	}
+
+	return tags
+}
+
+// bucket returns the bytes from the start of bucket i and its bit offset in
+// the first byte, the offset of the bit does not overflow for large tables.
+func (p *PackedTable) bucket(i uint32) ([]byte, uint32) {
+	off := uint64(i) * uint64(p.kBitsPerBucket)
+	return p.buckets[off>>3:], uint32(off & 7)
+}
+
+func (p *PackedTable) writeTags(i uint32, tags packedTags) {
+	n := int(p.tagsPerBucket)
+	// insertion sort by the low 4 bits
+	for j := 1; j < n; j++ {
+		for k := j; k > 0 && tags[k]&0x0f < tags[k-1]&0x0f; k-- {
+			tags[k], tags[k-1] = tags[k-1], tags[k]
+		}
+	}
+
+	var lowBits [8]uint8
+	for j := 0; j < n; j++ {
+		lowBits[j] = uint8(tags[j] & 0x0f)
+	}
+
+	b, off := p.bucket(i)
+	if n == 4 {
+		code := p.perm.Encode([4]uint8{lowBits[0], lowBits[1], lowBits[2], lowBits[3]})
+		writeBits(b, off, 12, uint64(code))
+		off += 12
+	} else {
+		writeBits(b, off, kRankCodewordBits, uint64(rankEncode(lowBits)))
+		off += kRankCodewordBits
+	}
+
+	for j := 0; j < n; j++ {
+		writeBits(b, off, p.kDirBitsPerTag, uint64(tags[j]>>4))
+		off += p.kDirBitsPerTag
+	}
 }
 
 func (p *PackedTable) sortPair(a, b *uint32) {
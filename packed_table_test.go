@@ -0,0 +1,194 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRankEncoding(t *testing.T) {
+	seen := make([]bool, kRankEnts)
+	var n int
+	var gen func(base, k int, dst [8]uint8)
+	gen = func(base, k int, dst [8]uint8) {
+		for v := base; v < 16; v++ {
+			dst[k] = uint8(v)
+			if k+1 < len(dst) {
+				gen(v, k+1, dst)
+				continue
+			}
+
+			code := rankEncode(dst)
+			if code >= kRankEnts || seen[code] {
+				t.Fatalf("%v encodes to bad or repeated codeword %v", dst, code)
+			}
+			seen[code] = true
+			n++
+
+			if got := rankDecode(code); got != dst {
+				t.Fatalf("decode %v = %v, want %v", code, got, dst)
+			}
+		}
+	}
+	gen(0, 0, [8]uint8{})
+
+	if n != kRankEnts {
+		t.Errorf("%v tuples, want %v", n, kRankEnts)
+	}
+}
+
+func TestPackedTable_Buckets(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, tagsPerBucket := range []uint32{4, 8} {
+		for _, bits := range NewPackedTable().Limits().BitsPerItem {
+			const numBucket = 32
+			table := NewPackedTable()
+			table.Init(numBucket, tagsPerBucket, bits)
+
+			want := make([]map[uint64]int, numBucket)
+			for i := range want {
+				want[i] = map[uint64]int{}
+				for j := uint32(0); j < tagsPerBucket; j++ {
+					tag := r.Uint64()&(1<<bits-1) | 1
+					if _, ok := table.Insert(uint32(i), tag, false); !ok {
+						t.Fatalf("tags %v bits %v: bucket %v full after %v", tagsPerBucket, bits, i, j)
+					}
+					want[i][tag]++
+				}
+
+				if _, ok := table.Insert(uint32(i), 1, false); ok {
+					t.Fatalf("tags %v bits %v: insert into full bucket %v", tagsPerBucket, bits, i)
+				}
+			}
+
+			for i := range want {
+				got := map[uint64]int{}
+				for _, tag := range table.ReadBucket(uint32(i), nil) {
+					got[tag]++
+					if !table.Find(uint32(i), tag) {
+						t.Errorf("tags %v bits %v: find %#x in %v fail", tagsPerBucket, bits, tag, i)
+					}
+				}
+
+				for tag, n := range want[i] {
+					if got[tag] != n {
+						t.Errorf("tags %v bits %v bucket %v: tag %#x %v times, want %v", tagsPerBucket, bits, i, tag, got[tag], n)
+					}
+				}
+			}
+
+			// empty every other bucket, the neighbours must survive
+			for i := 0; i < numBucket; i += 2 {
+				for tag, n := range want[i] {
+					for ; n > 0; n-- {
+						if !table.Delete(uint32(i), tag) {
+							t.Errorf("tags %v bits %v: delete %#x from %v fail", tagsPerBucket, bits, tag, i)
+						}
+					}
+				}
+			}
+
+			for i := 1; i < numBucket; i += 2 {
+				for tag := range want[i] {
+					if !table.Find(uint32(i), tag) {
+						t.Errorf("tags %v bits %v: lost %#x in %v", tagsPerBucket, bits, tag, i)
+					}
+				}
+			}
+		}
+	}
+}
+
+// the generic layout must read what the fast paths write and back
+func TestPackedTable_Layout(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, bits := range []uint32{5, 6, 7, 8, 9, 13, 17} {
+		const numBucket = 9
+		table := NewPackedTable()
+		table.Init(numBucket, 4, bits)
+
+		for i := uint32(0); i < numBucket; i++ {
+			var tags packedTags
+			for j := 0; j < 4; j++ {
+				tags[j] = r.Uint32() & (1<<bits - 1)
+			}
+
+			table.writeTag(i, [4]uint32{tags[0], tags[1], tags[2], tags[3]}, true)
+			fast := table.readTag(i)
+			if got := table.readTags(i); [4]uint32{got[0], got[1], got[2], got[3]} != fast {
+				t.Errorf("bits %v bucket %v: generic read %v, fast %v", bits, i, got[:4], fast)
+			}
+
+			// tags with the same low bits may swap places
+			table.writeTags(i, tags)
+			if got := table.readTag(i); sortedTags(got) != sortedTags(fast) {
+				t.Errorf("bits %v bucket %v: fast read %v after generic write, want %v", bits, i, got, fast)
+			}
+		}
+	}
+}
+
+func sortedTags(tags [4]uint32) [4]uint32 {
+	sort.Slice(tags[:], func(a, b int) bool { return tags[a] < tags[b] })
+	return tags
+}
+
+func TestPackedTable_Filter8(t *testing.T) {
+	filter := NewCuckooFilter(WithSeed(1), WithNumKeys(10000), WithTagsPerBucket(8), WithBitsPerItem(13), WithTable(NewPackedTable()))
+
+	var inserted int
+	for ; inserted < 10000; inserted++ {
+		if err := filter.Add([]byte(strconv.Itoa(inserted))); err != nil {
+			t.Fatalf("add %v: %v", inserted, err)
+		}
+	}
+
+	for i := 0; i < inserted; i++ {
+		if !filter.Contain([]byte(strconv.Itoa(i))) {
+			t.Errorf("find %v fail", i)
+		}
+	}
+
+	if lf := filter.LoadFactor(); lf < 0.6 {
+		t.Errorf("load factor %v", lf)
+	}
+
+	for i := 0; i < inserted; i++ {
+		if !filter.Delete([]byte(strconv.Itoa(i))) {
+			t.Errorf("delete %v fail", i)
+		}
+	}
+}
+
+func TestPackedTable_SharedPerm(t *testing.T) {
+	tables := make([]*PackedTable, 8)
+	var wg sync.WaitGroup
+	for k := range tables {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			tables[k] = NewPackedTable()
+			tables[k].Init(16, 4, 13)
+			tables[k].Insert(1, 0x1234, false)
+		}(k)
+	}
+	wg.Wait()
+
+	for _, table := range tables {
+		if table.perm != tables[0].perm {
+			t.Fatal("tables do not share the permutation encoding")
+		}
+		if !table.Find(1, 0x1234) {
+			t.Error("find 0x1234 fail")
+		}
+	}
+}
+
+func BenchmarkPackedTable_Init(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewPackedTable().Init(16, 4, 13)
+	}
+}
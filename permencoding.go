@@ -1,5 +1,7 @@
 package cuckoo
 
+import "sync"
+
 const (
 	N_ENTS = 3876
 )
@@ -21,6 +23,20 @@ func NewPermEncoding() *PermEncoding {
 	return p
 }
 
+var (
+	permOnce sync.Once
+	perm     *PermEncoding
+)
+
+// sharedPermEncoding returns the PermEncoding shared by all packed tables,
+// the tables are built on first use and never change after.
+func sharedPermEncoding() *PermEncoding {
+	permOnce.Do(func() {
+		perm = NewPermEncoding()
+	})
+	return perm
+}
+
 func (p *PermEncoding) Encode(lowBits [4]uint8) uint16 {
 	return p.encTable[p.pack(lowBits)]
 }
@@ -62,3 +78,52 @@ func (p *PermEncoding) genTables(base, k int, dst [4]uint8, idx *uint16) {
 		}
 	}
 }
+
+const (
+	// number of sorted 8-tuples of 4-bit numbers, C(23, 8)
+	kRankEnts = 490314
+	// bits of a rank codeword, kRankEnts < 1<<19
+	kRankCodewordBits = 19
+)
+
+// binomial[n][k] = C(n, k) for n < 23, k <= 8
+var binomial = func() (b [23][9]uint32) {
+	for n := range b {
+		b[n][0] = 1
+		for k := 1; k <= n && k < 9; k++ {
+			b[n][k] = b[n-1][k-1]
+			if k < n {
+				b[n][k] += b[n-1][k]
+			}
+		}
+	}
+	return b
+}()
+
+/* rankEncode encodes the low 4 bits of 8 tags sorted ascending. A sorted
+ * tuple v0 <= v1 <= ... <= v7 maps to the strictly increasing c_j = v_j + j
+ * in [0, 23), whose rank sum(C(c_j, j+1)) in the combinatorial number system
+ * is below C(23, 8). 32 bits of low bits fit in a 19 bits codeword.
+ */
+func rankEncode(lowBits [8]uint8) uint32 {
+	var code uint32
+	for j, v := range lowBits {
+		code += binomial[int(v)+j][j+1]
+	}
+	return code
+}
+
+// rankDecode returns the sorted low bits of a rankEncode codeword
+func rankDecode(code uint32) [8]uint8 {
+	var lowBits [8]uint8
+	c := 22
+	for j := 7; j >= 0; j-- {
+		for binomial[c][j+1] > code {
+			c--
+		}
+		code -= binomial[c][j+1]
+		lowBits[j] = uint8(c - j)
+		c--
+	}
+	return lowBits
+}
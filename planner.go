@@ -0,0 +1,124 @@
+package cuckoo
+
+import (
+	"fmt"
+	"math"
+)
+
+// planLoad is the load factor a random walk reaches with b tags per bucket,
+// from the cuckoo filter paper.
+var planLoad = map[uint32]float64{
+	2: 0.84,
+	4: 0.95,
+	8: 0.98,
+}
+
+// FilterPlan is a filter configuration returned by Plan
+type FilterPlan struct {
+	// Options create the filter, a table given by them is new for every
+	// filter.
+	Options []Option
+
+	Table           string
+	TagsPerBucket   uint32
+	FingerprintBits uint32
+	// BitsPerItem is the memory per item at the planned capacity
+	BitsPerItem float64
+	// LoadFactor is the table load at the planned capacity
+	LoadFactor float64
+	// FalsePositiveRate is the upper bound 2*TagsPerBucket/2^FingerprintBits
+	FalsePositiveRate float64
+	// Bytes is the memory of the table
+	Bytes uint64
+}
+
+/*
+	the paper bounds the false positive rate by 2b/2^f, so
+	f >= log2(2b/fpr) and the filter takes f/α bits per item, α is the load
+	factor b tags per bucket reach. A packed table stores the low 4 bits of
+	the sorted tags of a bucket in one codeword, 1 bit less per tag with 4
+	tags, 13 bits less per bucket with 8.
+*/
+
+// Plan returns the smallest filter that holds capacity items with a false
+// positive rate of at most fpr, it picks the packed table when that takes
+// less memory. maxBytes limits the table memory, 0 for no limit.
+// The error wraps ErrInvalidOptions when no configuration fits.
+func Plan(capacity uint64, fpr float64, maxBytes uint64) (FilterPlan, error) {
+	if capacity == 0 || !(fpr > 0 && fpr < 1) {
+		return FilterPlan{}, fmt.Errorf("%w: plan for capacity %v and false positive rate %v", ErrInvalidOptions, capacity, fpr)
+	}
+
+	var best FilterPlan
+	for _, name := range []string{SingleTable, PackedTableName} {
+		for _, b := range []uint32{2, 4, 8} {
+			p, ok := plan(name, capacity, fpr, b)
+			// the single table is faster, the packed one has to save space
+			if ok && (best.Options == nil || p.Bytes < best.Bytes) {
+				best = p
+			}
+		}
+	}
+
+	if best.Options == nil {
+		return FilterPlan{}, fmt.Errorf("%w: no table holds %v items at false positive rate %v", ErrInvalidOptions, capacity, fpr)
+	}
+
+	if maxBytes != 0 && best.Bytes > maxBytes {
+		return best, fmt.Errorf("%w: %v items at false positive rate %v take %v bytes, more than %v", ErrInvalidOptions, capacity, fpr, best.Bytes, maxBytes)
+	}
+
+	return best, nil
+}
+
+func plan(name string, capacity uint64, fpr float64, b uint32) (FilterPlan, bool) {
+	f := uint32(math.Ceil(math.Log2(2 * float64(b) / fpr)))
+	if f < 1 {
+		f = 1
+	}
+
+	t, err := newTable(name)
+	if err != nil || t.Limits().Check(b, f) != nil {
+		return FilterPlan{}, false
+	}
+
+	// WithExactCapacity sizes for a load of 0.96, scale the keys so the
+	// table is sized for the load b tags reach.
+	numKeys := math.Ceil(float64(capacity) * 0.96 / planLoad[b])
+	if numKeys > math.MaxUint32 {
+		return FilterPlan{}, false
+	}
+
+	opts := []Option{
+		WithNumKeys(uint32(numKeys)),
+		WithTagsPerBucket(b),
+		WithBitsPerItem(f),
+		WithExactCapacity(),
+		func(options *Options) {
+			options.table, _ = newTable(name)
+		},
+	}
+
+	var opt Options
+	for _, o := range opts {
+		o(&opt)
+	}
+	opt.apply()
+	if opt.validate() != nil {
+		return FilterPlan{}, false
+	}
+
+	numBucket := uint64(opt.numBuckets())
+	bytes, _ := tableBytes(name, opt.numBuckets(), b, f)
+
+	return FilterPlan{
+		Options:           opts,
+		Table:             name,
+		TagsPerBucket:     b,
+		FingerprintBits:   f,
+		BitsPerItem:       8 * float64(bytes) / float64(capacity),
+		LoadFactor:        float64(capacity) / float64(numBucket*uint64(b)),
+		FalsePositiveRate: 2 * float64(b) / math.Exp2(float64(f)),
+		Bytes:             bytes,
+	}, true
+}
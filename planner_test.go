@@ -0,0 +1,62 @@
+package cuckoo
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	ts := []struct {
+		capacity uint64
+		fpr      float64
+		table    string
+	}{
+		{capacity: 100000, fpr: 0.01, table: PackedTableName},
+		{capacity: 100000, fpr: 0.001, table: PackedTableName},
+		{capacity: 100000, fpr: 1e-12, table: SingleTable},
+	}
+
+	for _, te := range ts {
+		p, err := Plan(te.capacity, te.fpr, 0)
+		if err != nil {
+			t.Fatalf("%v %v: %v", te.capacity, te.fpr, err)
+		}
+
+		if p.Table != te.table {
+			t.Errorf("%v %v: table %v, want %v", te.capacity, te.fpr, p.Table, te.table)
+		}
+
+		if p.FalsePositiveRate > te.fpr || p.LoadFactor > 0.98 {
+			t.Errorf("%v %v: plan %+v", te.capacity, te.fpr, p)
+		}
+
+		filter := NewCuckooFilter(append(p.Options, WithSeed(1))...)
+		for i := 0; i < int(te.capacity); i++ {
+			if err := filter.Add([]byte(strconv.Itoa(i))); err != nil {
+				t.Fatalf("%v %v: add %v: %v", te.capacity, te.fpr, i, err)
+			}
+		}
+
+		if filter.table.String() != p.Table {
+			t.Errorf("%v %v: filter table %v", te.capacity, te.fpr, filter.table)
+		}
+
+		if tb, _ := filter.table.MarshalBinary(); uint64(len(tb)) != p.Bytes {
+			t.Errorf("%v %v: table takes %v bytes, planned %v", te.capacity, te.fpr, len(tb), p.Bytes)
+		}
+
+		// a second filter from the same options gets its own table
+		if other := NewCuckooFilter(p.Options...); other.table == filter.table {
+			t.Errorf("%v %v: filters share a table", te.capacity, te.fpr)
+		}
+	}
+
+	if _, err := Plan(100000, 0.001, 1000); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expect ErrInvalidOptions for 1000 bytes, got %v", err)
+	}
+
+	if _, err := Plan(100000, 0, 0); !errors.Is(err, ErrInvalidOptions) {
+		t.Errorf("expect ErrInvalidOptions for fpr 0, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package cuckoo
+
+const (
+	// a layer stops taking items above this load factor
+	scaleLoadFactor = 0.9
+	// each layer holds scaleGrowth times the items of the previous one
+	scaleGrowth = 2
+)
+
+// ScalableFilter grows by adding a new, larger Cuckoo layer when the current
+// one is full, so the capacity does not have to be known up front.
+//
+// A lookup checks every layer, so the false positive rate is the sum of the
+// layer rates, about 2*tagsPerBucket/2^bitsPerItem each. Every new layer
+// uses at least one more fingerprint bit, the rates halve from layer to
+// layer and the total stays below twice the rate of the first layer, until
+// the layers reach the widest fingerprint of the table (64 bits for the
+// single table, 32 for the packed one). Later layers keep that width and
+// each adds its rate.
+type ScalableFilter struct {
+	opts   []Option
+	opt    Options
+	layers []*Cuckoo
+}
+
+// NewScalableFilter takes the same options as NewCuckooFilter, WithNumKeys
+// and WithBitsPerItem size the first layer. Every layer gets a new table of
+// the kind given by WithTable, so WithTable must be one of the built-in
+// tables.
+func NewScalableFilter(opts ...Option) *ScalableFilter {
+	var opt Options
+	for _, o := range opts {
+		o(&opt)
+	}
+	opt.apply()
+
+	s := &ScalableFilter{
+		opts: append([]Option(nil), opts...),
+		opt:  opt,
+	}
+	s.addLayer(opt.numKeys, opt.bitsPerItem)
+	return s
+}
+
+func (s *ScalableFilter) Insert(x []byte) bool {
+	return s.Add(x) == nil
+}
+
+// Add never returns ErrFilterFull, a full layer is followed by a new one.
+func (s *ScalableFilter) Add(x []byte) error {
+	c := s.layers[len(s.layers)-1]
+	if c.IsFull() || c.LoadFactor() >= scaleLoadFactor {
+		c = s.addLayer(c.opt.numKeys*scaleGrowth, s.nextBitsPerItem(c.bitsPerItem))
+	}
+
+	i, tag := c.indexTag(s.hash(x))
+	return c.addTag(i, tag)
+}
+
+func (s *ScalableFilter) Contain(item []byte) bool {
+	hv, hv2 := s.hash(item)
+	for _, c := range s.layers {
+		i, tag := c.indexTag(hv, hv2)
+		if c.containTag(i, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *ScalableFilter) Delete(item []byte) bool {
+	hv, hv2 := s.hash(item)
+	// newer layers hold most of the items
+	for k := len(s.layers) - 1; k >= 0; k-- {
+		c := s.layers[k]
+		i, tag := c.indexTag(hv, hv2)
+		if c.deleteTag(i, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Len returns the number of items stored in all layers
+func (s *ScalableFilter) Len() uint32 {
+	var n uint32
+	for _, c := range s.layers {
+		n += c.Len()
+	}
+
+	return n
+}
+
+func (s *ScalableFilter) LoadFactor() float64 {
+	count, size, _ := s.stats()
+	return 1.0 * float64(count) / float64(size)
+}
+
+// BitsPerItem returns the table memory of all layers per stored item
+func (s *ScalableFilter) BitsPerItem() float64 {
+	count, _, bits := s.stats()
+	return float64(bits) / float64(count)
+}
+
+// Layers returns the number of layers
+func (s *ScalableFilter) Layers() int {
+	return len(s.layers)
+}
+
+// stats returns the items, the slots and the table bits of all layers
+func (s *ScalableFilter) stats() (count, size, bits uint64) {
+	for _, c := range s.layers {
+		count += uint64(c.Len())
+		size += uint64(c.table.SizeInTags()) + uint64(c.opt.stashSize)
+		bits += c.tableBits()
+	}
+
+	return count, size, bits
+}
+
+// hash returns the item hashes for every layer, the newest layer has the
+// widest tags.
+func (s *ScalableFilter) hash(item []byte) (uint64, uint64) {
+	return hashItem(item, s.opt.hf, s.layers[len(s.layers)-1].wide())
+}
+
+func (s *ScalableFilter) addLayer(numKeys, bitsPerItem uint32) *Cuckoo {
+	t, err := newTable(s.opt.table.String())
+	if err != nil {
+		panic(err)
+	}
+
+	c := NewCuckooFilter(append(s.opts,
+		WithNumKeys(numKeys),
+		WithBitsPerItem(bitsPerItem),
+		WithTable(t),
+		withHashOf(s.opt),
+	)...)
+	s.layers = append(s.layers, c)
+	return c
+}
+
+// nextBitsPerItem returns the smallest width above bits the table stores,
+// bits once there is none.
+func (s *ScalableFilter) nextBitsPerItem(bits uint32) uint32 {
+	next := bits
+	for _, w := range s.opt.table.Limits().BitsPerItem {
+		if w > bits && (next == bits || w < next) {
+			next = w
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,80 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestScalableFilter(t *testing.T) {
+	ts := []struct {
+		bitsPerItem uint32
+		table       func() Table
+	}{
+		{bitsPerItem: 8, table: func() Table { return nil }},
+		{bitsPerItem: 6, table: func() Table { return NewPackedTable() }},
+	}
+
+	const n = 20000
+	for _, te := range ts {
+		filter := NewScalableFilter(
+			WithSeed(1),
+			WithRand(rand.NewSource(1)),
+			WithNumKeys(100),
+			WithBitsPerItem(te.bitsPerItem),
+			WithTable(te.table()),
+		)
+
+		for i := 0; i < n; i++ {
+			if !filter.Insert([]byte(strconv.Itoa(i))) {
+				t.Fatalf("insert %v fail", i)
+			}
+		}
+
+		if filter.Layers() < 2 {
+			t.Errorf("expect more layers, got %v", filter.Layers())
+		}
+
+		if filter.Len() != n {
+			t.Errorf("len %v, want %v", filter.Len(), n)
+		}
+
+		var bits int
+		for _, c := range filter.layers {
+			tb, _ := c.table.MarshalBinary()
+			bits += 8 * len(tb)
+		}
+		if bpi := filter.BitsPerItem(); bpi != float64(bits)/n {
+			t.Errorf("bits per item %v, want %v", bpi, float64(bits)/n)
+		}
+
+		for i := 0; i < n; i++ {
+			if !filter.Contain([]byte(strconv.Itoa(i))) {
+				t.Errorf("find %v fail", i)
+			}
+		}
+
+		// bounded by twice the rate of the first layer
+		var falsePositive int
+		for i := n; i < 2*n; i++ {
+			if filter.Contain([]byte(strconv.Itoa(i))) {
+				falsePositive++
+			}
+		}
+
+		rate := float64(falsePositive) / n
+		if bound := 2 * 2 * 4 / float64(uint32(1)<<te.bitsPerItem); rate > bound {
+			t.Errorf("false positive rate %v above %v with %v layers", rate, bound, filter.Layers())
+		}
+
+		for i := 0; i < n; i++ {
+			if !filter.Delete([]byte(strconv.Itoa(i))) {
+				t.Errorf("delete %v fail", i)
+			}
+		}
+
+		if filter.Len() != 0 {
+			t.Errorf("len %v after deleting all", filter.Len())
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package cuckoo
+
+import (
+	"fmt"
+	"hash"
+	"math/rand"
+)
+
+// ShardedFilter spreads items over independent filters, each with its own
+// lock and hasher, so writers on different shards do not contend.
+// An item is routed by the high bits of its remixed hash, the remaining
+// hash bits pick the bucket and tag inside the shard.
+type ShardedFilter struct {
+	opt    Options
+	shift  uint
+	shards []*ConcurrentFilter
+
+	// route hashes an item to its shard when a hash.Hash64 given by
+	// WithHash is cloned for every shard, nil for the built-in hashes.
+	route sum64er
+}
+
+// NewShardedFilter creates a filter with shards rounded up to a power of two.
+// It takes the same options as NewCuckooFilter, WithNumKeys is the capacity
+// of the whole filter and is split evenly between the shards. Every shard
+// gets a new table of the kind given by WithTable, so WithTable must be one
+// of the built-in tables. A hash given by WithHash must implement
+// hash.Cloner, every shard hashes with its own clone.
+func NewShardedFilter(shards int, opts ...Option) *ShardedFilter {
+	var opt Options
+	for _, o := range opts {
+		o(&opt)
+	}
+	opt.apply()
+
+	if shards < 1 {
+		shards = 1
+	}
+	n := upperPow32(uint32(shards))
+	f := &ShardedFilter{
+		opt:    opt,
+		shards: make([]*ConcurrentFilter, n),
+	}
+	for n > 1 {
+		n >>= 1
+		f.shift++
+	}
+	f.shift = 64 - f.shift
+	if _, ok := opt.hf.(sum64er); !ok {
+		// a stateful hash is hashed under the lock of the shard, the
+		// shard is picked by a built-in hash first
+		f.route = newSeededHash(opt.seed)
+	}
+
+	numKeys := (opt.numKeys + uint32(len(f.shards)) - 1) / uint32(len(f.shards))
+	for i := range f.shards {
+		t, err := newTable(opt.table.String())
+		if err != nil {
+			panic(err)
+		}
+
+		hashOf := opt
+		if f.route != nil {
+			if hashOf.hf, err = cloneHash(opt.hf); err != nil {
+				panic(err)
+			}
+		}
+
+		// a copy, appending to opts could write to the caller's array
+		shardOpts := append(append([]Option(nil), opts...),
+			WithNumKeys(numKeys),
+			WithTable(t),
+			withHashOf(hashOf),
+		)
+		// shards are locked apart, each needs its own source
+		if opt.rand != nil {
+			shardOpts = append(shardOpts, WithRand(rand.NewSource(opt.rand.Int63())))
+		}
+		f.shards[i] = NewConcurrentFilter(shardOpts...)
+	}
+
+	return f
+}
+
+func (f *ShardedFilter) Insert(x []byte) bool {
+	return f.Add(x) == nil
+}
+
+func (f *ShardedFilter) Add(x []byte) error {
+	if f.route != nil {
+		return f.shard(f.route.sum64(x)).Add(x)
+	}
+
+	hv, hv2 := f.hash(x)
+	return f.shard(hv).addHash(hv, hv2)
+}
+
+// IsFull reports whether any shard is full
+func (f *ShardedFilter) IsFull() bool {
+	for _, s := range f.shards {
+		if s.IsFull() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *ShardedFilter) Contain(item []byte) bool {
+	if f.route != nil {
+		return f.shard(f.route.sum64(item)).Contain(item)
+	}
+
+	hv, hv2 := f.hash(item)
+	return f.shard(hv).containHash(hv, hv2)
+}
+
+func (f *ShardedFilter) Delete(item []byte) bool {
+	if f.route != nil {
+		return f.shard(f.route.sum64(item)).Delete(item)
+	}
+
+	hv, hv2 := f.hash(item)
+	return f.shard(hv).deleteHash(hv, hv2)
+}
+
+// Len returns the number of items stored in all shards
+func (f *ShardedFilter) Len() uint32 {
+	var n uint32
+	for _, s := range f.shards {
+		n += s.Len()
+	}
+
+	return n
+}
+
+func (f *ShardedFilter) LoadFactor() float64 {
+	count, size, _ := f.stats()
+	return 1.0 * float64(count) / float64(size)
+}
+
+// BitsPerItem returns the table memory of all shards per stored item
+func (f *ShardedFilter) BitsPerItem() float64 {
+	count, _, bits := f.stats()
+	return float64(bits) / float64(count)
+}
+
+// Shards returns the number of shards
+func (f *ShardedFilter) Shards() int {
+	return len(f.shards)
+}
+
+// stats returns the items, the slots and the table bits of all shards
+func (f *ShardedFilter) stats() (count, size, bits uint64) {
+	for _, s := range f.shards {
+		s.mu.RLock()
+		count += uint64(s.c.Len())
+		size += uint64(s.c.table.SizeInTags()) + uint64(s.c.opt.stashSize)
+		bits += s.c.tableBits()
+		s.mu.RUnlock()
+	}
+
+	return count, size, bits
+}
+
+func (f *ShardedFilter) shard(hv uint64) *ConcurrentFilter {
+	if f.shift == 64 {
+		return f.shards[0]
+	}
+
+	// remix so the shard does not depend on the index and tag bits
+	return f.shards[fmix64(hv)>>f.shift]
+}
+
+// hash hashes an item with a built-in hash, which is safe for concurrent use
+func (f *ShardedFilter) hash(item []byte) (uint64, uint64) {
+	return hashItem(item, f.opt.hf, f.opt.bitsPerItem > 32)
+}
+
+// cloneHash returns a copy of h with its own state
+func cloneHash(h hash.Hash64) (hash.Hash64, error) {
+	c, ok := h.(hash.Cloner)
+	if !ok {
+		return nil, fmt.Errorf("%w: a hash given by WithHash to a ShardedFilter must implement hash.Cloner", ErrInvalidOptions)
+	}
+
+	clone, err := c.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("%w: clone hash: %v", ErrInvalidOptions, err)
+	}
+
+	h64, ok := clone.(hash.Hash64)
+	if !ok {
+		return nil, fmt.Errorf("%w: clone of hash is not a hash.Hash64", ErrInvalidOptions)
+	}
+
+	return h64, nil
+}
+
+// withHashOf shares the hash function of o
+func withHashOf(o Options) Option {
+	return func(options *Options) {
+		options.hf = o.hf
+		options.hashKind = o.hashKind
+		options.seed = o.seed
+	}
+}
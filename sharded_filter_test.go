@@ -0,0 +1,150 @@
+package cuckoo
+
+import (
+	"errors"
+	"hash"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// run with -race
+func TestShardedFilter(t *testing.T) {
+	const workers, perWorker = 8, 1000
+	for _, shards := range []int{1, 3, 8} {
+		filter := NewShardedFilter(shards, WithNumKeys(workers*perWorker*2), WithSeed(1))
+		if filter.Shards() != int(upperPow32(uint32(shards))) {
+			t.Errorf("shards %v, got %v", shards, filter.Shards())
+		}
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					bs := []byte(strconv.Itoa(w*perWorker + i))
+					if !filter.Insert(bs) {
+						t.Errorf("insert %s fail", bs)
+					}
+
+					if !filter.Contain(bs) {
+						t.Errorf("find %s fail", bs)
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		if filter.Len() != workers*perWorker {
+			t.Errorf("len %v, want %v", filter.Len(), workers*perWorker)
+		}
+
+		for _, s := range filter.shards {
+			if s.Len() == 0 {
+				t.Errorf("empty shard of %v", shards)
+			}
+		}
+
+		if lf := filter.LoadFactor(); lf <= 0 || lf > 1 {
+			t.Errorf("load factor %v", lf)
+		}
+
+		var bits int
+		for _, s := range filter.shards {
+			tb, _ := s.c.table.MarshalBinary()
+			bits += 8 * len(tb)
+		}
+		if bpi, want := filter.BitsPerItem(), float64(bits)/float64(workers*perWorker); bpi != want {
+			t.Errorf("bits per item %v, want %v", bpi, want)
+		}
+
+		for i := 0; i < workers*perWorker; i++ {
+			bs := []byte(strconv.Itoa(i))
+			if !filter.Delete(bs) {
+				t.Errorf("delete %v fail", i)
+			}
+		}
+
+		if filter.Len() != 0 {
+			t.Errorf("len %v after deleting all", filter.Len())
+		}
+	}
+}
+
+// run with -race, every shard hashes with its own clone of the hash
+func TestShardedFilter_WithHash(t *testing.T) {
+	const workers, perWorker = 8, 1000
+	filter := NewShardedFilter(4, WithNumKeys(workers*perWorker*2), WithHash(fnv.New64a()))
+	for _, s := range filter.shards {
+		if s.c.opt.hf == filter.opt.hf {
+			t.Fatal("shards share the hash")
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				bs := []byte(strconv.Itoa(w*perWorker + i))
+				if !filter.Insert(bs) {
+					t.Errorf("insert %s fail", bs)
+				}
+
+				if !filter.Contain(bs) {
+					t.Errorf("find %s fail", bs)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers*perWorker; i++ {
+		if !filter.Delete([]byte(strconv.Itoa(i))) {
+			t.Errorf("delete %v fail", i)
+		}
+	}
+
+	if filter.Len() != 0 {
+		t.Errorf("len %v after deleting all", filter.Len())
+	}
+
+	defer func() {
+		if err, _ := recover().(error); !errors.Is(err, ErrInvalidOptions) {
+			t.Errorf("expect ErrInvalidOptions for a hash that can not be cloned, got %v", err)
+		}
+	}()
+	NewShardedFilter(4, WithHash(struct{ hash.Hash64 }{fnv.New64a()}))
+}
+
+func BenchmarkShardedFilter_Insert(b *testing.B) {
+	keys := make([][]byte, 1<<16)
+	for i := range keys {
+		keys[i] = []byte(strconv.Itoa(i))
+	}
+
+	run := func(b *testing.B, insert func([]byte) bool, del func([]byte) bool) {
+		b.RunParallel(func(pb *testing.PB) {
+			var i int
+			for pb.Next() {
+				k := keys[i&(len(keys)-1)]
+				insert(k)
+				del(k)
+				i++
+			}
+		})
+	}
+
+	b.Run("concurrent", func(b *testing.B) {
+		f := NewConcurrentFilter(WithNumKeys(1 << 20))
+		run(b, f.Insert, f.Delete)
+	})
+
+	b.Run("sharded", func(b *testing.B) {
+		f := NewShardedFilter(16, WithNumKeys(1<<20))
+		run(b, f.Insert, f.Delete)
+	})
+}
@@ -5,14 +5,16 @@ import (
 	"math/rand"
 )
 
-type fingerprint []byte
-
+// singleTable keeps all buckets in one byte array, bucket i is at
+// i*bytesPerBucket.
 type singleTable struct {
-	numBucket     uint32
-	tagsPerBucket uint32
-	bitsPerItem   uint32
-	tagMask       uint32
-	buckets       []fingerprint
+	numBucket       uint32
+	tagsPerBucket   uint32
+	bitsPerItem     uint32
+	tagMask         uint64
+	kBytesPerBucket int
+	buckets         []byte
+	rand            *rand.Rand
 }
 
 func (t *singleTable) SizeInTags() uint32 {
@@ -24,15 +26,11 @@ func (t *singleTable) Init(numBucket, tagsPerBucket, bitsPerItem uint32) {
 	t.tagsPerBucket = tagsPerBucket
 	t.bitsPerItem = bitsPerItem
 	t.tagMask = (1 << bitsPerItem) - 1
-	t.buckets = make([]fingerprint, numBucket)
-	kBytesPerFingerprint := (bitsPerItem*tagsPerBucket + 7) >> 3
-	var i uint32
-	for i = 0; i < numBucket; i++ {
-		t.buckets[i] = make(fingerprint, kBytesPerFingerprint)
-	}
+	t.kBytesPerBucket = t.bytesPerBucket()
+	t.buckets = make([]byte, int(numBucket)*t.kBytesPerBucket)
 }
 
-func (t *singleTable) Insert(i uint32, tag uint32, kickout bool) (oldTag uint32, ok bool) {
+func (t *singleTable) Insert(i uint32, tag uint64, kickout bool) (oldTag uint64, ok bool) {
 	var j uint32
 	for j = 0; j < t.tagsPerBucket; j++ {
 		if t.readTag(i, j) == 0 {
@@ -45,13 +43,13 @@ func (t *singleTable) Insert(i uint32, tag uint32, kickout bool) (oldTag uint32,
 		return tag, false
 	}
 
-	var r uint32 = uint32(rand.Intn(int(t.tagsPerBucket)))
+	var r uint32 = uint32(randIntn(t.rand, int(t.tagsPerBucket)))
 	oldTag = t.readTag(i, r)
 	t.writeTag(i, r, tag)
 	return oldTag, false
 }
 
-func (t *singleTable) Delete(i uint32, tag uint32) bool {
+func (t *singleTable) Delete(i uint32, tag uint64) bool {
 	var j uint32
 	for j = 0; j < t.tagsPerBucket; j++ {
 		if t.readTag(i, j) == tag {
@@ -63,7 +61,7 @@ func (t *singleTable) Delete(i uint32, tag uint32) bool {
 	return false
 }
 
-func (t *singleTable) Find(i uint32, tag uint32) bool {
+func (t *singleTable) Find(i uint32, tag uint64) bool {
 	var j uint32
 	for j = 0; j < t.tagsPerBucket; j++ {
 		if t.readTag(i, j) == tag {
@@ -74,6 +72,30 @@ func (t *singleTable) Find(i uint32, tag uint32) bool {
 	return false
 }
 
+func (t *singleTable) ReadBucket(i uint32, tags []uint64) []uint64 {
+	var j uint32
+	for j = 0; j < t.tagsPerBucket; j++ {
+		tags = append(tags, t.readTag(i, j))
+	}
+
+	return tags
+}
+
+func (t *singleTable) SetRand(r *rand.Rand) {
+	t.rand = r
+}
+
+func (t *singleTable) Limits() Limits {
+	bits := make([]uint32, 64)
+	for i := range bits {
+		bits[i] = uint32(i + 1)
+	}
+
+	return Limits{
+		BitsPerItem: bits,
+	}
+}
+
 func (t *singleTable) Info() string {
 	return fmt.Sprintf("SingleHashtable with tag size:%v bits \n"+
 		"\t\tAssociativity: %v \n"+
@@ -86,12 +108,35 @@ func (t *singleTable) String() string {
 	return SingleTable
 }
 
-func (t *singleTable) writeTag(i, j, tag uint32) {
-	fp := t.buckets[i]
+func (t *singleTable) MarshalBinary() ([]byte, error) {
+	data := make([]byte, len(t.buckets))
+	copy(data, t.buckets)
+	return data, nil
+}
+
+func (t *singleTable) UnmarshalBinary(data []byte) error {
+	if len(data) != len(t.buckets) {
+		return fmt.Errorf("%w: single table expects %v bytes, got %v", ErrCorrupt, len(t.buckets), len(data))
+	}
+
+	copy(t.buckets, data)
+	return nil
+}
+
+func (t *singleTable) bytesPerBucket() int {
+	return int((t.bitsPerItem*t.tagsPerBucket + 7) >> 3)
+}
+
+// writeTag and readTag have fast paths for 2, 4, 8, 12, 16 and 32 bits,
+// other widths are packed at bit offset j*bitsPerItem of the bucket.
+func (t *singleTable) writeTag(i, j uint32, tag uint64) {
+	fp := t.bucket(i)
 	tag = tag & t.tagMask
 	/* following code only works for little-endian */
 	if t.bitsPerItem == 2 {
-		fp[0] |= byte(tag << (2 * j))
+		pos, shift := j>>2, (j&3)<<1
+		fp[pos] &^= 3 << shift
+		fp[pos] |= byte(tag << shift)
 	} else if t.bitsPerItem == 4 {
 		pos := j >> 1
 		if j&1 == 0 {
@@ -124,30 +169,39 @@ func (t *singleTable) writeTag(i, j, tag uint32) {
 		fp[pos+1] = byte(tag >> 8)
 		fp[pos+2] = byte(tag >> 16)
 		fp[pos+3] = byte(tag >> 24)
+	} else {
+		writeBits(fp, j*t.bitsPerItem, t.bitsPerItem, tag)
 	}
 }
 
-func (t *singleTable) readTag(i, j uint32) uint32 {
+func (t *singleTable) readTag(i, j uint32) uint64 {
 	/* following code only works for little-endian */
-	fp := t.buckets[i]
-	var tag uint32 = 0
+	fp := t.bucket(i)
+	var tag uint64 = 0
 	if t.bitsPerItem == 2 {
-		tag = uint32(fp[0] >> (j * 2))
+		tag = uint64(fp[j>>2] >> ((j & 3) << 1))
 	} else if t.bitsPerItem == 4 {
 		pos := j >> 1
-		tag = uint32(fp[pos] >> ((j & 1) << 2))
+		tag = uint64(fp[pos] >> ((j & 1) << 2))
 	} else if t.bitsPerItem == 8 {
-		tag = uint32(fp[j])
+		tag = uint64(fp[j])
 	} else if t.bitsPerItem == 12 {
 		pos := j + (j >> 1)
-		tag = (uint32(fp[pos]) | uint32(fp[pos+1])<<8) >> ((j & 1) << 2)
+		tag = (uint64(fp[pos]) | uint64(fp[pos+1])<<8) >> ((j & 1) << 2)
 	} else if t.bitsPerItem == 16 {
 		pos := j << 1
-		tag = uint32(fp[pos]) | uint32(fp[pos+1])<<8
+		tag = uint64(fp[pos]) | uint64(fp[pos+1])<<8
 	} else if t.bitsPerItem == 32 {
 		pos := j << 2
-		tag = uint32(fp[pos]) | uint32(fp[pos+1])<<8 | uint32(fp[pos+2])<<16 | uint32(fp[pos+3])<<24
+		tag = uint64(fp[pos]) | uint64(fp[pos+1])<<8 | uint64(fp[pos+2])<<16 | uint64(fp[pos+3])<<24
+	} else {
+		tag = readBits(fp, j*t.bitsPerItem, t.bitsPerItem)
 	}
 
 	return tag & t.tagMask
 }
+
+func (t *singleTable) bucket(i uint32) []byte {
+	off := int(i) * t.kBytesPerBucket
+	return t.buckets[off : off+t.kBytesPerBucket]
+}
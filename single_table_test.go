@@ -0,0 +1,87 @@
+package cuckoo
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func TestSingleTable_Widths(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for bits := uint32(1); bits <= 64; bits++ {
+		for _, tagsPerBucket := range []uint32{1, 2, 3, 4, 5, 8} {
+			const numBucket = 16
+			table := &singleTable{}
+			table.Init(numBucket, tagsPerBucket, bits)
+
+			want := make([]uint64, numBucket*tagsPerBucket)
+			for k := range want {
+				want[k] = r.Uint64() & table.tagMask
+				table.writeTag(uint32(k)/tagsPerBucket, uint32(k)%tagsPerBucket, want[k])
+			}
+
+			// overwrite every other slot, the neighbours must survive
+			for k := 0; k < len(want); k += 2 {
+				want[k] = r.Uint64() & table.tagMask
+				table.writeTag(uint32(k)/tagsPerBucket, uint32(k)%tagsPerBucket, want[k])
+			}
+
+			for k := range want {
+				if got := table.readTag(uint32(k)/tagsPerBucket, uint32(k)%tagsPerBucket); got != want[k] {
+					t.Fatalf("bits %v tags %v slot %v: got %#x want %#x", bits, tagsPerBucket, k, got, want[k])
+				}
+			}
+		}
+	}
+}
+
+func TestSingleTable_FilterWidths(t *testing.T) {
+	for bits := uint32(1); bits <= 64; bits++ {
+		filter, err := NewCuckooFilterE(WithSeed(1), WithNumKeys(1000), WithBitsPerItem(bits), WithTagsPerBucket(3))
+		if err != nil {
+			t.Fatalf("bits %v: %v", bits, err)
+		}
+
+		for i := 0; i < 300; i++ {
+			if err := filter.Add([]byte(strconv.Itoa(i))); err != nil {
+				t.Fatalf("bits %v add %v: %v", bits, i, err)
+			}
+		}
+
+		for i := 0; i < 300; i++ {
+			if !filter.Contain([]byte(strconv.Itoa(i))) {
+				t.Errorf("bits %v find %v fail", bits, i)
+			}
+		}
+	}
+}
+
+func BenchmarkSingleTable_Init(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		table := &singleTable{}
+		table.Init(1<<20, 4, 12)
+	}
+}
+
+func BenchmarkSingleTable_Find(b *testing.B) {
+	const numBucket = 1 << 20
+	table := &singleTable{}
+	table.Init(numBucket, 4, 12)
+	r := rand.New(rand.NewSource(1))
+	for i := uint32(0); i < numBucket; i++ {
+		for j := 0; j < 3; j++ {
+			table.Insert(i, uint64(r.Intn(1<<12-1)+1), false)
+		}
+	}
+
+	idx := make([]uint32, 1<<16)
+	for k := range idx {
+		idx[k] = uint32(r.Intn(numBucket))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table.Find(idx[i&(len(idx)-1)], 1)
+	}
+}
@@ -0,0 +1,145 @@
+package cuckoo
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+var (
+	_ hash.Hash64 = &sipHash{}
+	_ sum64er     = &sipHash{}
+)
+
+// sipHash is SipHash-2-4 with a secret 128-bit key. Without the key the
+// buckets of an item can not be predicted, so keys can not be crafted to
+// collide. The second hash of sum128 uses the key with k0 remixed.
+type sipHash struct {
+	k0, k1 uint64
+	s      sipState
+}
+
+func newSipHash(key [16]byte) *sipHash {
+	h := &sipHash{
+		k0: binary.LittleEndian.Uint64(key[:8]),
+		k1: binary.LittleEndian.Uint64(key[8:]),
+	}
+	h.Reset()
+	return h
+}
+
+// check returns the key check value recorded by MarshalBinary
+func (h *sipHash) check() uint64 {
+	return h.sum64(hashCheckMessage)
+}
+
+func (h *sipHash) Write(p []byte) (int, error) {
+	h.s.write(p)
+	return len(p), nil
+}
+
+func (h *sipHash) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, h.Sum64())
+}
+
+func (h *sipHash) Reset() {
+	h.s = newSipState(h.k0, h.k1)
+}
+
+func (h *sipHash) Size() int {
+	return 8
+}
+
+func (h *sipHash) BlockSize() int {
+	return 8
+}
+
+func (h *sipHash) Sum64() uint64 {
+	s := h.s
+	return s.sum()
+}
+
+func (h *sipHash) sum64(b []byte) uint64 {
+	s := newSipState(h.k0, h.k1)
+	s.write(b)
+	return s.sum()
+}
+
+func (h *sipHash) sum128(b []byte) (uint64, uint64) {
+	s := newSipState(h.k0^seed2Mix, h.k1)
+	s.write(b)
+	return h.sum64(b), s.sum()
+}
+
+// sipState is the streaming state of SipHash-2-4
+type sipState struct {
+	v0, v1, v2, v3 uint64
+	tail           uint64 // bytes not yet in a full 8 byte word
+	ntail          int
+	n              uint64 // length of the input
+}
+
+func newSipState(k0, k1 uint64) sipState {
+	return sipState{
+		v0: k0 ^ 0x736f6d6570736575,
+		v1: k1 ^ 0x646f72616e646f6d,
+		v2: k0 ^ 0x6c7967656e657261,
+		v3: k1 ^ 0x7465646279746573,
+	}
+}
+
+func (s *sipState) write(p []byte) {
+	s.n += uint64(len(p))
+	for s.ntail != 0 && len(p) > 0 {
+		s.tail |= uint64(p[0]) << (8 * s.ntail)
+		p = p[1:]
+		s.ntail++
+		if s.ntail == 8 {
+			s.block(s.tail)
+			s.tail, s.ntail = 0, 0
+		}
+	}
+
+	for ; len(p) >= 8; p = p[8:] {
+		s.block(binary.LittleEndian.Uint64(p))
+	}
+
+	for _, b := range p {
+		s.tail |= uint64(b) << (8 * s.ntail)
+		s.ntail++
+	}
+}
+
+func (s *sipState) block(m uint64) {
+	s.v3 ^= m
+	s.round()
+	s.round()
+	s.v0 ^= m
+}
+
+func (s *sipState) sum() uint64 {
+	s.block(s.tail | s.n<<56)
+	s.v2 ^= 0xff
+	s.round()
+	s.round()
+	s.round()
+	s.round()
+	return s.v0 ^ s.v1 ^ s.v2 ^ s.v3
+}
+
+func (s *sipState) round() {
+	s.v0 += s.v1
+	s.v1 = bits.RotateLeft64(s.v1, 13)
+	s.v1 ^= s.v0
+	s.v0 = bits.RotateLeft64(s.v0, 32)
+	s.v2 += s.v3
+	s.v3 = bits.RotateLeft64(s.v3, 16)
+	s.v3 ^= s.v2
+	s.v0 += s.v3
+	s.v3 = bits.RotateLeft64(s.v3, 21)
+	s.v3 ^= s.v0
+	s.v2 += s.v1
+	s.v1 = bits.RotateLeft64(s.v1, 17)
+	s.v1 ^= s.v2
+	s.v2 = bits.RotateLeft64(s.v2, 32)
+}
@@ -0,0 +1,156 @@
+package cuckoo
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestSipHash(t *testing.T) {
+	var key [16]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	h := newSipHash(key)
+
+	// vectors from the SipHash paper and reference implementation, the
+	// message is 0, 1, 2, ... n-1
+	want := map[int]uint64{
+		0:  0x726fdb47dd0e0e31,
+		1:  0x74f839c593dc67fd,
+		7:  0xab0200f58b01d137,
+		8:  0x93f5f5799a932462,
+		15: 0xa129ca6149be45e5,
+		63: 0x958a324ceb064572,
+	}
+	for n, sum := range want {
+		msg := make([]byte, n)
+		for i := range msg {
+			msg[i] = byte(i)
+		}
+
+		if got := h.sum64(msg); got != sum {
+			t.Errorf("len %v: sum64 %#x, want %#x", n, got, sum)
+		}
+
+		// written in pieces of every size
+		for step := 1; step <= 9; step++ {
+			h.Reset()
+			for i := 0; i < n; i += step {
+				h.Write(msg[i:min(i+step, n)])
+			}
+			if got := h.Sum64(); got != sum {
+				t.Errorf("len %v step %v: Sum64 %#x, want %#x", n, step, got, sum)
+			}
+		}
+	}
+}
+
+func TestWithKey(t *testing.T) {
+	key := [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	filter := NewCuckooFilter(WithKey(key), WithNumKeys(1000))
+	for i := 0; i < 500; i++ {
+		filter.Insert([]byte(strconv.Itoa(i)))
+	}
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, key[:8]) || bytes.Contains(data, key[8:]) {
+		t.Error("marshaled filter holds the key")
+	}
+
+	loaded := NewCuckooFilter(WithKey(key))
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 500; i++ {
+		if !loaded.Contain([]byte(strconv.Itoa(i))) {
+			t.Errorf("find %v fail", i)
+		}
+	}
+
+	other := key
+	other[0]++
+	for _, c := range []*Cuckoo{{}, NewCuckooFilter(), NewCuckooFilter(WithSeed(1)), NewCuckooFilter(WithKey(other))} {
+		if err := c.UnmarshalBinary(data); !errors.Is(err, ErrHashMismatch) {
+			t.Errorf("expect ErrHashMismatch, got %v", err)
+		}
+	}
+}
+
+func TestWithFloodCallback(t *testing.T) {
+	type report struct{ i1, i2, n uint32 }
+	var reports []report
+	newFilter := func(opts ...Option) *Cuckoo {
+		reports = nil
+		return NewCuckooFilter(append([]Option{WithSeed(1), WithNumKeys(64),
+			WithFloodCallback(2, func(i1, i2, n uint32) {
+				reports = append(reports, report{i1, i2, n})
+			})}, opts...)...)
+	}
+
+	// items that all go to buckets 0 and 1 bounce between them
+	var flood [][]byte
+	filter := newFilter()
+	for i := 0; len(flood) < 40; i++ {
+		item := []byte(strconv.Itoa(i))
+		i1, tag := filter.generateIndexTagHash(item)
+		if i2 := filter.altIndex(i1, tag); i1|i2 == 1 && i1 != i2 {
+			flood = append(flood, item)
+		}
+	}
+
+	// 8 fill both buckets, the 9th runs out of kicks and is stashed, every
+	// later one is rejected with ErrFilterFull and reported
+	for _, item := range flood {
+		filter.Add(item)
+	}
+	if len(reports) != len(flood)-9 {
+		t.Fatalf("%v reports: %v", len(reports), reports)
+	}
+	for k, r := range reports {
+		if r != (report{0, 1, uint32(k + 2)}) {
+			t.Errorf("report %v: %v", k, r)
+		}
+	}
+
+	// a stashed fingerprint moved back on delete is not an add
+	reports = nil
+	for _, item := range flood[:9] {
+		filter.Delete(item)
+	}
+	if len(reports) != 0 {
+		t.Errorf("reports on delete: %v", reports)
+	}
+
+	// new pairs do not evict the pair under attack
+	flooded := [2]uint32{0, 1}
+	for k := uint32(1); k <= 4*floodMaxPairs; k++ {
+		filter.exhausted.add([2]uint32{1000 + k, 2000 + k})
+		if k%floodMaxPairs == 0 {
+			filter.exhausted.add(flooded)
+		}
+	}
+	if len(filter.exhausted.slots) != floodMaxPairs || filter.exhausted.count(flooded) != uint32(len(flood)-8+4) {
+		t.Errorf("%v pairs, %v exhaustions of the flooded pair", len(filter.exhausted.slots), filter.exhausted.count(flooded))
+	}
+
+	// with a larger stash every add after the first 8 runs out of kicks
+	filter = newFilter(WithStashSize(8))
+	for _, item := range flood[:16] {
+		filter.Add(item)
+	}
+	if len(reports) != 7 {
+		t.Fatalf("%v reports: %v", len(reports), reports)
+	}
+	for k, r := range reports {
+		if r != (report{0, 1, uint32(k + 2)}) {
+			t.Errorf("report %v: %v", k, r)
+		}
+	}
+}
@@ -1,7 +1,14 @@
 package cuckoo
 
+import (
+	"encoding"
+	"fmt"
+	"math/rand"
+)
+
 const (
-	SingleTable = "single-table"
+	SingleTable     = "single-table"
+	PackedTableName = "packed_table"
 )
 
 var (
@@ -11,10 +18,92 @@ var (
 
 type Table interface {
 	Init(numBucket, tagsPerBucket, bitsPerItem uint32)
-	Insert(i uint32, tag uint32, kickout bool) (oldTag uint32, ok bool)
-	Delete(i uint32, tag uint32) bool
-	Find(i1 uint32, tag uint32) bool
+	Insert(i uint32, tag uint64, kickout bool) (oldTag uint64, ok bool)
+	Delete(i uint32, tag uint64) bool
+	Find(i1 uint32, tag uint64) bool
+	// ReadBucket appends the tags of bucket i to tags, 0 for an empty slot
+	ReadBucket(i uint32, tags []uint64) []uint64
+	// Limits returns the parameters Init supports
+	Limits() Limits
+	// SetRand sets the source Insert picks kicked out slots from, nil for
+	// the global math/rand source.
+	SetRand(r *rand.Rand)
 	SizeInTags() uint32
 	Info() string
 	String() string
+
+	// MarshalBinary/UnmarshalBinary dump and load the raw buckets,
+	// UnmarshalBinary expects the table to be initialized with the same
+	// parameters it was marshaled with.
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// tableBytes returns the length of the buckets a built-in table marshals
+// for valid parameters, ok is false for other tables.
+func tableBytes(name string, numBucket, tagsPerBucket, bitsPerItem uint32) (n uint64, ok bool) {
+	numBuckets, b, f := uint64(numBucket), uint64(tagsPerBucket), uint64(bitsPerItem)
+	switch {
+	case name == SingleTable:
+		return numBuckets * ((b*f + 7) >> 3), true
+	case name != PackedTableName:
+		return 0, false
+	case b == 4:
+		// as PackedTable.Init
+		return numBuckets*(((3+f-4)*4+7)>>3) + 7, true
+	default:
+		return (numBuckets*(kRankCodewordBits+(f-4)*8)+7)>>3 + 7, true
+	}
+}
+
+// randIntn returns rand.Intn(n) from r, or from the global source for a nil r
+func randIntn(r *rand.Rand, n int) int {
+	if r == nil {
+		return rand.Intn(n)
+	}
+
+	return r.Intn(n)
+}
+
+// Limits lists the parameters a Table supports
+type Limits struct {
+	// BitsPerItem lists the supported fingerprint widths
+	BitsPerItem []uint32
+	// TagsPerBucket lists the supported bucket sizes, empty means any
+	TagsPerBucket []uint32
+}
+
+// Check returns an error naming the first parameter out of the limits
+func (l Limits) Check(tagsPerBucket, bitsPerItem uint32) error {
+	if !l.has(l.BitsPerItem, bitsPerItem) {
+		return fmt.Errorf("bitsPerItem %v not in %v", bitsPerItem, l.BitsPerItem)
+	}
+
+	if len(l.TagsPerBucket) != 0 && !l.has(l.TagsPerBucket, tagsPerBucket) {
+		return fmt.Errorf("tagsPerBucket %v not in %v", tagsPerBucket, l.TagsPerBucket)
+	}
+
+	return nil
+}
+
+func (l Limits) has(list []uint32, n uint32) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newTable returns an empty table by the name its String method reports.
+func newTable(name string) (Table, error) {
+	switch name {
+	case SingleTable:
+		return &singleTable{}, nil
+	case PackedTableName:
+		return NewPackedTable(), nil
+	}
+
+	return nil, fmt.Errorf("cuckoo: unknown table %q", name)
 }
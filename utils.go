@@ -5,11 +5,40 @@ import (
 )
 
 func hash64(src []byte, hash hash.Hash64) uint64 {
+	if s, ok := hash.(sum64er); ok {
+		return s.sum64(src)
+	}
+
 	hash.Reset()
 	hash.Write(src)
 	return hash.Sum64()
 }
 
+// hashItem returns hash64 of src, and the second hash of hash128 when wide
+func hashItem(src []byte, hash hash.Hash64, wide bool) (uint64, uint64) {
+	if wide {
+		return hash128(src, hash)
+	}
+
+	return hash64(src, hash), 0
+}
+
+// hash128 returns the hash64 of src and a second hash independent of it,
+// a hash.Hash64 given by WithHash gets a marker byte appended for the second.
+func hash128(src []byte, hash hash.Hash64) (uint64, uint64) {
+	if s, ok := hash.(sum64er); ok {
+		return s.sum128(src)
+	}
+
+	hash.Reset()
+	hash.Write(src)
+	hv := hash.Sum64()
+	hash.Write(hash128Marker[:])
+	return hv, hash.Sum64()
+}
+
+var hash128Marker = [1]byte{0x80}
+
 func upperPow(x uint64) uint64 {
 	if x == 0 {
 		return 1
@@ -38,3 +67,36 @@ func upperPow32(x uint32) uint32 {
 	x++
 	return x
 }
+
+// readBits returns the n <= 64 bits at bit offset off of b, bits are counted
+// from the least significant bit of b[0] upwards.
+func readBits(b []byte, off, n uint32) uint64 {
+	pos, shift := off>>3, off&7
+	var v uint64
+	for k := uint32(0); k < 8 && k*8 < shift+n; k++ {
+		v |= uint64(b[pos+k]) << (k * 8)
+	}
+	v >>= shift
+	if shift+n > 64 {
+		v |= uint64(b[pos+8]) << (64 - shift)
+	}
+
+	return v & (1<<n - 1)
+}
+
+// writeBits stores the low n <= 64 bits of v at bit offset off of b
+func writeBits(b []byte, off, n uint32, v uint64) {
+	pos, shift := off>>3, off&7
+	mask := uint64(1)<<n - 1
+	v &= mask
+	for k := uint32(0); k*8 < shift+n; k++ {
+		// bits of the mask and value that land in byte pos+k
+		var m, val byte
+		if k == 0 {
+			m, val = byte(mask<<shift), byte(v<<shift)
+		} else {
+			m, val = byte(mask>>(k*8-shift)), byte(v>>(k*8-shift))
+		}
+		b[pos+k] = b[pos+k]&^m | val
+	}
+}